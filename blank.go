@@ -0,0 +1,121 @@
+package nmea0183
+
+import (
+	"strings"
+)
+
+// What a blank field in an updated sentence does to the variable it sets
+type BlankPolicy int
+
+const (
+	BlankOverwrite BlankPolicy = iota // the variable is set blank, present but empty
+	BlankIgnore                       // the variable keeps its last value
+	BlankClear                        // the variable is removed as if it had expired
+)
+
+// Whether a variable has a value, see ValueState
+type VariableState int
+
+const (
+	Absent  VariableState = iota // never set
+	Cleared                      // set before but removed by a blank field, expiry or DeleteBefore
+	Empty                        // present but blank
+	Present                      // present with a value
+)
+
+// Sets the policy for blank fields of keys, or the default for variables without a policy if no
+// keys are given.  The default is BlankOverwrite.  Policies of variables are also used when
+// they are prefixed by ParsePrefixVar eg a policy for dbt also applies to b_dbt.
+func (h *Handle) SetBlankPolicy(policy BlankPolicy, keys ...string) {
+	if len(keys) == 0 {
+		h.settings.blankPolicy = policy
+		return
+	}
+	if h.blankPolicies == nil {
+		h.blankPolicies = make(map[string]BlankPolicy)
+	}
+	for _, key := range keys {
+		h.blankPolicies[key] = policy
+	}
+}
+
+// Returns whether a variable is present with a value, present but empty, cleared or absent.
+// Values of cleared variables are also removed from GetFrom and recorded blank in the history
+// kept by KeepHistory.
+func (h *Handle) ValueState(key string) VariableState {
+	if v, ok := h.data[key]; ok {
+		if len(v) == 0 {
+			return Empty
+		}
+		return Present
+	}
+	if _, ok := h.history[key]; ok {
+		return Cleared
+	}
+	return Absent
+}
+
+// Returns the value of a variable to write and its state, taken from values by name if given
+// there otherwise from the data set by key
+func (h *Handle) writeValue(key, name string, values map[string]string) (string, VariableState) {
+	value, ok := values[name]
+	if !ok {
+		return h.data[key], h.ValueState(key)
+	}
+	if len(value) == 0 {
+		return value, Empty
+	}
+	return value, Present
+}
+
+func (h *Handle) blankPolicy(key string) BlankPolicy {
+	if policy, ok := h.blankPolicies[key]; ok {
+		return policy
+	}
+	if h.parsing != nil && len(h.parsing.prefix) > 0 && len(key) > len(h.parsing.prefix) {
+		if policy, ok := h.blankPolicies[key[len(h.parsing.prefix):]]; ok {
+			return policy
+		}
+	}
+	return h.settings.blankPolicy
+}
+
+// Returns results without blank variables which are to be ignored or cleared, clearing those to
+// be cleared at timeStamp
+func (h *Handle) applyBlankPolicy(results map[string]string, timeStamp int64) map[string]string {
+	if h.blankPolicies == nil && h.settings.blankPolicy == BlankOverwrite {
+		return results
+	}
+	applied := make(map[string]string, len(results))
+	for n, v := range results {
+		if len(v) > 0 {
+			applied[n] = v
+			continue
+		}
+		switch h.blankPolicy(n) {
+		case BlankOverwrite:
+			applied[n] = v
+		case BlankClear:
+			h.clearVariable(n, timeStamp)
+		}
+	}
+	return applied
+}
+
+// clears a variable given a blank field from the data set of the source being parsed and, if
+// present, from the data set with its source and validity mark, recording the clear in its history
+func (h *Handle) clearVariable(key string, timeStamp int64) {
+	if h.parsing != nil {
+		delete(h.sourceData[h.parsing.Name], strings.TrimPrefix(key, h.parsing.prefix))
+	}
+	if _, ok := h.data[key]; !ok {
+		return
+	}
+	delete(h.data, key)
+	delete(h.sources, key)
+	delete(h.invalid, key)
+	h.history[key] = timeStamp
+	if h.valueLogs != nil {
+		h.logValue(key, "", timeStamp)
+	}
+}
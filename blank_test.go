@@ -0,0 +1,81 @@
+package nmea0183
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlankPolicy(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	if nm.ValueState("dbt") != Absent {
+		t.Error("dbt should be absent")
+	}
+	nm.Parse("$SDDPT,12.3,0.5*62")
+	nm.Parse("$SDDPT,,0.5*7C")
+	if nm.ValueState("dbt") != Empty {
+		t.Errorf("by default a blank overwrites got %s", nm.Get("dbt"))
+	}
+
+	nm.SetBlankPolicy(BlankIgnore, "dbt")
+	nm.Parse("$SDDPT,12.3,0.5*62")
+	nm.Parse("$SDDPT,,*57")
+	if nm.Get("dbt") != "12.3" || nm.ValueState("toff") != Empty {
+		t.Errorf("ignored blank got dbt %s toff %s", nm.Get("dbt"), nm.Get("toff"))
+	}
+	// the policy also applies to prefixed variables
+	nm.ParsePrefixVar("$SDDPT,11.0,0.5*62", "b_")
+	nm.ParsePrefixVar("$SDDPT,,0.5*7C", "b_")
+	if nm.Get("b_dbt") != "11.0" {
+		t.Errorf("ignored prefixed blank got %s", nm.Get("b_dbt"))
+	}
+
+	nm.SetBlankPolicy(BlankClear)
+	nm.Parse("$SDDPT,12.3,*49")
+	if nm.ValueState("toff") != Cleared || nm.ValueState("dbt") != Present {
+		t.Errorf("cleared toff got %v dbt %v", nm.ValueState("toff"), nm.ValueState("dbt"))
+	}
+
+	// cleared variables are missing when written, empty ones are not
+	if s, err := nm.WriteSentence("SD", "DPT"); s != "$SDDPT,12.3,*49" || err == nil || !strings.Contains(err.Error(), "toff") {
+		t.Errorf("write with cleared toff got %s %v", s, err)
+	}
+	nm.SetBlankPolicy(BlankOverwrite, "toff")
+	nm.Update(map[string]string{"toff": ""})
+	if s, err := nm.WriteSentence("SD", "DPT"); s != "$SDDPT,12.3,*49" || err != nil {
+		t.Errorf("write with empty toff got %s %v", s, err)
+	}
+	// prefixed variables and given values follow the same rule
+	if s, err := nm.WriteSentencePrefixVar("SD", "DPT", "b_"); s != "$SDDPT,11.0,0.5*62" || err != nil {
+		t.Errorf("write prefixed got %s %v", s, err)
+	}
+	if s, err := nm.WriteSentenceWith("SD", "DPT", map[string]string{"dbt": ""}); s != "$SDDPT,,*57" || err != nil {
+		t.Errorf("write with empty dbt got %s %v", s, err)
+	}
+}
+
+func TestBlankClearsEverywhere(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.KeepHistory(HistoryLimits{Count: 10}, "dbt")
+	nm.ValidityPreferences(MarkInvalid)
+	nm.SetBlankPolicy(BlankClear, "dbt")
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:00:00.00+00:00"})
+	nm.ParseFrom("sounder", "$SDDPT,12.3,0.5*62")
+	nm.invalid = map[string]bool{"dbt": true}
+
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:00:10.00+00:00"})
+	nm.ParseFrom("sounder", "$SDDPT,,0.5*7C")
+	if nm.ValueState("dbt") != Cleared || nm.GetFrom("sounder", "dbt") != "" || !nm.Valid("dbt") {
+		t.Errorf("cleared dbt got state %v source %q valid %v", nm.ValueState("dbt"), nm.GetFrom("sounder", "dbt"), nm.Valid("dbt"))
+	}
+	if _, ok := nm.SourceOf("dbt"); ok {
+		t.Error("cleared dbt should have no source")
+	}
+	if v, err := nm.ValueAt("dbt", time.Date(2020, 9, 15, 11, 0, 10, 0, time.UTC)); err != nil || v != "" {
+		t.Errorf("history after clear got %q %v", v, err)
+	}
+	if v, _ := nm.ValueAt("dbt", time.Date(2020, 9, 15, 11, 0, 5, 0, time.UTC)); v != "12.3" {
+		t.Errorf("history before clear got %q", v)
+	}
+}
@@ -0,0 +1,154 @@
+package nmea0183
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Samples selected handle variables at a fixed interval writing one CSV row per sample.
+// Lat, long and position variables are written as decimal degrees, position giving
+// two columns name_lat and name_long.  Compass and cross track error variables are written
+// as numbers, see Float.  Missing or stale values give blank cells.
+type CSVSampler struct {
+	h           *Handle
+	w           *csv.Writer
+	vars        []string
+	interval    int64 // in milliseconds
+	maxAge      int64 // in milliseconds, 0 never stale
+	messageTime bool
+	next        int64
+	header      bool
+	err         error
+}
+
+// Makes a sampler writing the given variables of h to w every interval.
+// By default rows are time stamped with the processor clock, values are never stale
+// and the sampler must be driven by calling Sample or Attach.
+func NewCSVSampler(h *Handle, w io.Writer, vars []string, interval time.Duration) *CSVSampler {
+	return &CSVSampler{
+		h:        h,
+		w:        csv.NewWriter(w),
+		vars:     vars,
+		interval: max(interval.Milliseconds(), 1),
+	}
+}
+
+// Values which were updated longer than maxAge before the sample time are written as
+// blank cells. Give 0 to write the last value however old
+func (s *CSVSampler) MaxAge(maxAge time.Duration) {
+	s.maxAge = maxAge.Milliseconds()
+}
+
+// true to time stamp rows and measure the interval using the message date of the sentences
+// parsed, as needed to replay a log, false to use the processor clock
+func (s *CSVSampler) UseMessageTime(messageTime bool) {
+	s.messageTime = messageTime
+}
+
+// Samples the handle as each update is made.  A row is written by the first update after each
+// interval has passed which suits both live data and replaying logs.
+func (s *CSVSampler) Attach() {
+	s.h.OnUpdate(func(map[string]string) {
+		s.Sample()
+	})
+}
+
+// Returns the first error met writing rows or nil
+func (s *CSVSampler) Err() error {
+	return s.err
+}
+
+// Writes a row if the interval has passed since the last row, can be called from a ticker
+// when live data might stop arriving.  The header row is written before the first row.
+// When using message time no rows are written until a sentence with a date has been parsed.
+func (s *CSVSampler) Sample() error {
+	if s.messageTime && s.h.messageDate.Year() <= 0 {
+		return nil
+	}
+	timeNow := s.clock()
+	if timeNow < s.next {
+		return nil
+	}
+	s.next = (timeNow/s.interval + 1) * s.interval
+	return s.WriteRow(time.UnixMilli(timeNow / s.interval * s.interval).UTC())
+}
+
+// Writes a row of the current values time stamped with sampleTime
+func (s *CSVSampler) WriteRow(sampleTime time.Time) error {
+	if !s.header {
+		s.header = true
+		s.write(s.Header())
+	}
+	row := []string{sampleTime.Format(time.RFC3339Nano)}
+	for _, v := range s.vars {
+		row = append(row, s.cells(v, sampleTime.UnixMilli())...)
+	}
+	s.write(row)
+	return s.err
+}
+
+// Returns the column names of the header row
+func (s *CSVSampler) Header() []string {
+	header := []string{"time"}
+	for _, v := range s.vars {
+		if tType, _ := getConversion(s.h.sentences.variables[v]); tType == "position" {
+			header = append(header, v+"_lat", v+"_long")
+		} else {
+			header = append(header, v)
+		}
+	}
+	return header
+}
+
+func (s *CSVSampler) cells(v string, sampleTime int64) []string {
+	tType, _ := getConversion(s.h.sentences.variables[v])
+	blank := []string{""}
+	if tType == "position" {
+		blank = []string{"", ""}
+	}
+	val, ok := s.h.data[v]
+	if !ok || len(val) == 0 {
+		return blank
+	}
+	if s.maxAge > 0 && sampleTime-s.h.history[v] > s.maxAge {
+		return blank
+	}
+	typed := typedValue(s.h.sentences.variables[v], val)
+	if f, ok := floatValue(typed); ok {
+		return []string{strconv.FormatFloat(f, 'f', -1, 64)}
+	}
+	switch t := typed.(type) {
+	case map[string]interface{}:
+		if tType == "position" {
+			return []string{
+				strconv.FormatFloat(t["lat"].(float64), 'f', -1, 64),
+				strconv.FormatFloat(t["long"].(float64), 'f', -1, 64),
+			}
+		}
+	case time.Time:
+		return []string{t.Format(time.RFC3339Nano)}
+	}
+	if tType == "position" {
+		return blank
+	}
+	return []string{val}
+}
+
+func (s *CSVSampler) clock() int64 {
+	if s.messageTime {
+		return s.h.messageDate.UnixMilli()
+	}
+	return time.Now().UTC().UnixMilli()
+}
+
+func (s *CSVSampler) write(row []string) {
+	if err := s.w.Write(row); err != nil && s.err == nil {
+		s.err = err
+	}
+	s.w.Flush()
+	if err := s.w.Error(); err != nil && s.err == nil {
+		s.err = err
+	}
+}
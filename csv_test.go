@@ -0,0 +1,42 @@
+package nmea0183
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVSampler(t *testing.T) {
+	var buf bytes.Buffer
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	sampler := NewCSVSampler(nm, &buf, []string{"position", "sog", "hdm", "dbt"}, time.Second)
+	sampler.UseMessageTime(true)
+	sampler.MaxAge(5 * time.Second)
+	sampler.Attach()
+
+	nm.Parse("$SSDPT,2.8,-0.7")
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Parse("$HCHDM,172.5,M*28")
+	nm.Parse("$GPRMC,110910.99,A,5047.3986,N,00054.6007,W,0.09,0.19,150920,0.24,W,D,V*78")
+	nm.Parse("$GPRMC,110920.00,A,5047.3986,N,00054.6007,W,0.10,0.19,150920,0.24,W,D,V*73")
+
+	if err := sampler.Err(); err != nil {
+		t.Fatalf("sampler error %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"time,position_lat,position_long,sog,hdm,dbt",
+		"2020-09-15T11:09:10Z,50.78997666666667,-0.9100116666666668,0.08,,",
+		"2020-09-15T11:09:20Z,50.78997666666667,-0.9100116666666668,0.1,,",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines got %d: %s", len(expected), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("line %d got %s expected %s", i, line, expected[i])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type currentState struct {
+	window  int64   // averaging window in milliseconds, 0 for none
+	leeway  float64 // degrees, positive when the boat is pushed to starboard
+	samples []currentSample
+}
+
+type currentSample struct {
+	ms          int64
+	north, east float64
+}
+
+// Set preferences used by Current:
+// window is the period the current is averaged over, 0 to use the latest values only
+// leeway in degrees is added to the heading to give the water track, positive when pushed to starboard
+func (h *Handle) CurrentPreferences(window time.Duration, leeway float64) {
+	h.current.window = window.Milliseconds()
+	h.current.leeway = leeway
+}
+
+// Computes set and drift each time the heading, speed through water or ground track is updated, see Current.
+// Values derived by the handle eg hdt from AttachHeadings do not add samples to the average.
+func (h *Handle) AttachCurrent() {
+	h.onParsedUpdate(func(results map[string]string) {
+		for _, key := range []string{"sog", "tmg", "stw", "hdm", "hdt"} {
+			if _, ok := results[key]; ok {
+				h.Current()
+				return
+			}
+		}
+	})
+}
+
+// Computes the current as the difference between the ground track (sog, tmg) and the water track
+// (true heading from hdt or hdm and variation plus leeway, stw) averaged over the window set by
+// CurrentPreferences and stores the results in the variables used by VDR:
+//
+//	set      direction the current flows towards True
+//	set_mag  as set but Magnetic if variation is known
+//	drift    speed of the current in knots
+func (h *Handle) Current() error {
+	sog, errSog := h.Float("sog")
+	tmg, errTmg := h.Float("tmg")
+	stw, errStw := h.Float("stw")
+	if errSog != nil || errTmg != nil || errStw != nil {
+		return fmt.Errorf("current needs sog, tmg and stw")
+	}
+	heading, err := h.trueHeading(true)
+	if err != nil {
+		return err
+	}
+	waterTrack := toRad(heading + h.current.leeway)
+	sample := currentSample{
+		ms:    h.timeNow(),
+		north: sog*math.Cos(toRad(tmg)) - stw*math.Cos(waterTrack),
+		east:  sog*math.Sin(toRad(tmg)) - stw*math.Sin(waterTrack),
+	}
+
+	samples := []currentSample{}
+	for _, s := range h.current.samples {
+		if h.current.window > 0 && sample.ms-s.ms < h.current.window {
+			samples = append(samples, s)
+		}
+	}
+	h.current.samples = append(samples, sample)
+
+	var north, east float64
+	for _, s := range h.current.samples {
+		north += s.north
+		east += s.east
+	}
+	north /= float64(len(h.current.samples))
+	east /= float64(len(h.current.samples))
+
+	set := wrap360(toDeg(math.Atan2(east, north)))
+	values := map[string]string{
+		"set":   compassStr(set, "T"),
+		"drift": strconv.FormatFloat(math.Hypot(north, east), 'f', 2, 64),
+	}
+	if magVar, _, err := h.variation(); err == nil {
+		values["set_mag"] = compassStr(wrap360(set-magVar), "M")
+	}
+	h.setVars(values)
+	return nil
+}
@@ -0,0 +1,62 @@
+package nmea0183
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCurrent(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AttachCurrent()
+	nm.Parse("$HCHDG,,,,10.0,W*24")
+	nm.Parse("$HCHDM,10.0,M*18")
+	nm.Parse("$VWVHM,,,,,6.0*56")
+	nm.Update(map[string]string{"sog": "7.0", "tmg": "20.0"})
+	if nm.Get("set") != "76.4°T" || nm.Get("set_mag") != "86.4°M" || nm.Get("drift") != "2.46" {
+		t.Errorf("current got %s %s %s", nm.Get("set"), nm.Get("set_mag"), nm.Get("drift"))
+	}
+	vdr, err := nm.WriteSentence("II", "VDR")
+	if err != nil || vdr[:len(vdr)-3] != "$IIVDR,76.4,T,86.4,M,2.46,N" {
+		t.Errorf("vdr got %s %v", vdr, err)
+	}
+
+	nm.CurrentPreferences(0, 5)
+	nm.Current()
+	if nm.Get("set") != "72.2°T" || nm.Get("drift") != "1.97" {
+		t.Errorf("current with leeway got %s %s", nm.Get("set"), nm.Get("drift"))
+	}
+}
+
+func TestCurrentFromParsedValues(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AttachCurrent()
+	nm.Parse("$HCHDG,,,,10.0,W*24")
+	nm.Parse("$HCHDM,10.0,M*18")
+	nm.Parse("$VWVHM,,,,,6.0*56")
+	nm.Update(map[string]string{"sog": "7.0", "tmg": "20.0"})
+	nm.setVars(map[string]string{"stw": "3.0"})
+	if nm.Get("set") != "76.4°T" || nm.Get("drift") != "2.46" {
+		t.Errorf("a derived stw should not trigger current got %s %s", nm.Get("set"), nm.Get("drift"))
+	}
+}
+
+func TestCurrentAveraging(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.CurrentPreferences(time.Minute, 0)
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:09:10.00+00:00", "hdt": "0.0°T", "stw": "6.0", "sog": "7.0", "tmg": "20.0"})
+	nm.Current()
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:09:20.00+00:00", "sog": "6.0", "tmg": "0.0"})
+	nm.Current()
+	if nm.Get("set") != "76.4°T" || nm.Get("drift") != "1.23" {
+		t.Errorf("averaged current got %s %s", nm.Get("set"), nm.Get("drift"))
+	}
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:10:30.00+00:00"})
+	nm.Current()
+	if nm.Get("drift") != "0.00" {
+		t.Errorf("samples outside the window should be dropped got %s", nm.Get("drift"))
+	}
+	if want := time.Date(2020, 9, 15, 11, 10, 30, 0, time.UTC); !nm.Date("drift").Equal(want) {
+		t.Errorf("drift time stamp got %s", nm.Date("drift"))
+	}
+}
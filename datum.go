@@ -0,0 +1,290 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A geodetic datum given by its ellipsoid and the Helmert transform from WGS84 to the datum.
+// Translations are in metres, scale in parts per million and rotations in arc seconds.
+type Datum struct {
+	Name       string
+	A, F       float64 // semi-major axis in metres and flattening of the ellipsoid
+	Tx, Ty, Tz float64
+	S          float64
+	Rx, Ry, Rz float64
+}
+
+// Built in datums by DTM local datum code, with IHO codes OGB and EUR also found by their
+// common names OSGB36 and ED50
+var Datums = map[string]Datum{
+	"W84": {Name: "WGS84", A: wgs84A, F: wgs84F},
+	"W72": {Name: "WGS72", A: 6378135, F: 1 / 298.26, Tz: -4.5, S: -0.22, Rz: 0.554},
+	"OGB": {Name: "OSGB36", A: 6377563.396, F: (6377563.396 - 6356256.909) / 6377563.396,
+		Tx: -446.448, Ty: 125.157, Tz: -542.060, S: 20.4894, Rx: -0.1502, Ry: -0.2470, Rz: -0.8421},
+	"EUR": {Name: "ED50", A: 6378388, F: 1 / 297.0, Tx: 89.5, Ty: 93.8, Tz: 123.1, S: -1.2, Rz: 0.156},
+}
+
+// Finds a built in datum by DTM code or name eg "OGB" or "OSGB36"
+func findDatum(code string) (Datum, bool) {
+	code = strings.ToUpper(code)
+	if d, ok := Datums[code]; ok {
+		return d, true
+	}
+	for _, d := range Datums {
+		if strings.ToUpper(d.Name) == code {
+			return d, true
+		}
+	}
+	return Datum{}, false
+}
+
+// Converts lat and long in decimal degrees on the datum (code or name) to WGS84
+func ToWGS84(lat, long float64, datum string) (float64, float64, error) {
+	d, ok := findDatum(datum)
+	if !ok {
+		return 0, 0, fmt.Errorf("datum %s is not known", datum)
+	}
+	x, y, z := toCartesian(lat, long, d.A, d.F)
+	inverse := Datum{Tx: -d.Tx, Ty: -d.Ty, Tz: -d.Tz, S: -d.S, Rx: -d.Rx, Ry: -d.Ry, Rz: -d.Rz}
+	x, y, z = inverse.helmert(x, y, z)
+	lat, long = fromCartesian(x, y, z, wgs84A, wgs84F)
+	return lat, long, nil
+}
+
+// Converts lat and long in decimal degrees on WGS84 to the datum (code or name)
+func FromWGS84(lat, long float64, datum string) (float64, float64, error) {
+	d, ok := findDatum(datum)
+	if !ok {
+		return 0, 0, fmt.Errorf("datum %s is not known", datum)
+	}
+	x, y, z := toCartesian(lat, long, wgs84A, wgs84F)
+	x, y, z = d.helmert(x, y, z)
+	lat, long = fromCartesian(x, y, z, d.A, d.F)
+	return lat, long, nil
+}
+
+func (d Datum) helmert(x, y, z float64) (float64, float64, float64) {
+	s := d.S/1e6 + 1
+	rx := toRad(d.Rx / 3600)
+	ry := toRad(d.Ry / 3600)
+	rz := toRad(d.Rz / 3600)
+	return d.Tx + x*s - y*rz + z*ry,
+		d.Ty + x*rz + y*s - z*rx,
+		d.Tz - x*ry + y*rx + z*s
+}
+
+// Earth centred cartesian coordinates of a point at zero height on an ellipsoid
+func toCartesian(lat, long, a, f float64) (float64, float64, float64) {
+	φ, λ := toRad(lat), toRad(long)
+	e2 := f * (2 - f)
+	ν := a / math.Sqrt(1-e2*math.Sin(φ)*math.Sin(φ))
+	return ν * math.Cos(φ) * math.Cos(λ), ν * math.Cos(φ) * math.Sin(λ), ν * (1 - e2) * math.Sin(φ)
+}
+
+func fromCartesian(x, y, z, a, f float64) (float64, float64) {
+	e2 := f * (2 - f)
+	p := math.Sqrt(x*x + y*y)
+	φ := math.Atan2(z, p*(1-e2))
+	for i := 0; i < 10; i++ {
+		ν := a / math.Sqrt(1-e2*math.Sin(φ)*math.Sin(φ))
+		φ = math.Atan2(z+e2*ν*math.Sin(φ), p)
+	}
+	return toDeg(φ), toDeg(math.Atan2(y, x))
+}
+
+// Set true to convert positions received in a local datum announced by a DTM sentence to WGS84.
+// Built in Helmert parameters are used for datums in Datums, otherwise the DTM lat and long offsets
+// are taken as the local datum position less the reference datum position.
+// Whether or not positions are converted once a DTM has been received the datum of each position
+// variable is recorded in a variable of the same name ending _datum eg position_datum
+func (h *Handle) NormaliseDatum(normalise bool) {
+	h.settings.normaliseDatum = normalise
+}
+
+// Sets the datum (code or name in Datums) positions are written in by WriteSentence.  Data is
+// assumed to be WGS84.  Give "" or W84 to write WGS84.  See also WriteDTM
+func (h *Handle) SetOutputDatum(datum string) error {
+	if len(datum) == 0 {
+		h.settings.outputDatum = ""
+		return nil
+	}
+	for code, d := range Datums {
+		if code == strings.ToUpper(datum) || strings.ToUpper(d.Name) == strings.ToUpper(datum) {
+			h.settings.outputDatum = code
+			return nil
+		}
+	}
+	return fmt.Errorf("datum %s is not known", datum)
+}
+
+// Returns a DTM sentence for the output datum set by SetOutputDatum with the offsets from WGS84
+// at the current position
+func (h *Handle) WriteDTM(manCode string) (string, error) {
+	code := h.settings.outputDatum
+	if len(code) == 0 {
+		code = "W84"
+	}
+	values := map[string]string{
+		"dtm_datum":       code,
+		"dtm_subdivision": "",
+		"dtm_lat_offset":  "0.0000",
+		"dtm_long_offset": "0.0000",
+		"dtm_alt_offset":  "0.0",
+		"dtm_ref":         "W84",
+	}
+	if lat, long, err := h.positionFloat("position"); err == nil && code != "W84" {
+		localLat, localLong, _ := FromWGS84(lat, long, code)
+		values["dtm_lat_offset"] = strconv.FormatFloat((localLat-lat)*60, 'f', 4, 64)
+		values["dtm_long_offset"] = strconv.FormatFloat((localLong-long)*60, 'f', 4, 64)
+	}
+	return h.WriteSentenceWith(manCode, "DTM", values)
+}
+
+// Records the datum of position variables in results once a DTM has been received and
+// converts them to WGS84 if NormaliseDatum is set.  Returns a copy if results are changed.
+func (h *Handle) recordDatum(results map[string]string) map[string]string {
+	datum, ok := results["dtm_datum"]
+	if !ok {
+		datum, ok = h.data["dtm_datum"]
+	}
+	if !ok || len(datum) == 0 {
+		return results
+	}
+	var names []string
+	for n := range results {
+		tType, _ := getConversion(h.sentences.variables[n])
+		if tType == "position" || tType == "lat" || tType == "long" {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return results
+	}
+	sort.Strings(names)
+	out := make(map[string]string)
+	for k, v := range results {
+		out[k] = v
+	}
+
+	ref := h.dtmValue(results, "dtm_ref")
+	if len(ref) == 0 {
+		ref = "W84"
+	}
+	if h.settings.normaliseDatum && datum != "W84" {
+		if _, known := findDatum(datum); known {
+			for n, v := range h.convertPositions(names, out, func(lat, long float64) (float64, float64) {
+				lat, long, _ = ToWGS84(lat, long, datum)
+				return lat, long
+			}) {
+				out[n] = v
+			}
+			datum = "W84"
+		} else {
+			latOffset, _ := strconv.ParseFloat(h.dtmValue(results, "dtm_lat_offset"), 64)
+			longOffset, _ := strconv.ParseFloat(h.dtmValue(results, "dtm_long_offset"), 64)
+			for n, v := range h.convertPositions(names, out, func(lat, long float64) (float64, float64) {
+				return lat - latOffset/60, long - longOffset/60
+			}) {
+				out[n] = v
+			}
+			datum = ref
+		}
+	}
+	for _, n := range names {
+		out[n+"_datum"] = datum
+	}
+	return out
+}
+
+// Returns a DTM variable from results or if not present data
+func (h *Handle) dtmValue(results map[string]string, key string) string {
+	if v, ok := results[key]; ok {
+		return v
+	}
+	return h.data[key]
+}
+
+// Returns values with the positions of a sentence converted to the output datum
+func (h *Handle) outputDatumValues(varList []string, prefixVar string, values map[string]string) map[string]string {
+	if len(h.settings.outputDatum) == 0 || h.settings.outputDatum == "W84" {
+		return values
+	}
+	out := make(map[string]string)
+	for _, v := range varList {
+		if val, ok := values[v]; ok {
+			out[v] = val
+		} else if val, ok := h.data[prefixVar+v]; ok {
+			out[v] = val
+		}
+	}
+	converted := h.convertPositions(varList, out, func(lat, long float64) (float64, float64) {
+		lat, long, _ = FromWGS84(lat, long, h.settings.outputDatum)
+		return lat, long
+	})
+	if len(converted) == 0 {
+		return values
+	}
+	for k, v := range values {
+		out[k] = v
+	}
+	for k, v := range converted {
+		out[k] = v
+	}
+	return out
+}
+
+// Converts the position variables of names found in values returning the new values with
+// the same number of decimal places of minutes.  The first lat and long variables
+// are converted as a pair.
+func (h *Handle) convertPositions(names []string, values map[string]string, conv func(lat, long float64) (float64, float64)) map[string]string {
+	out := make(map[string]string)
+	latName, longName := "", ""
+	for _, n := range names {
+		val := values[n]
+		if len(val) == 0 {
+			continue
+		}
+		tType, _ := getConversion(h.sentences.variables[n])
+		switch tType {
+		case "position":
+			if lat, long, err := LatLongToFloat(val); err == nil {
+				lat, long = conv(lat, long)
+				latStr, longStr, _ := LatLongToStringFormat(lat, long, DegreesDecimalMinutes, minuteDecimals(val))
+				out[n] = latStr + ", " + longStr
+			}
+		case "lat":
+			if len(latName) == 0 {
+				latName = n
+			}
+		case "long":
+			if len(longName) == 0 {
+				longName = n
+			}
+		}
+	}
+	if len(latName) > 0 && len(longName) > 0 {
+		if lat, long, err := LatLongToFloat(values[latName], values[longName]); err == nil {
+			lat, long = conv(lat, long)
+			latStr, longStr, _ := LatLongToStringFormat(lat, long, DegreesDecimalMinutes, minuteDecimals(values[latName]))
+			out[latName] = latStr
+			out[longName] = longStr
+		}
+	}
+	return out
+}
+
+// Returns the number of decimal places of the first minutes in a position string
+func minuteDecimals(pos string) int {
+	mins, _, found := strings.Cut(pos, "'")
+	if !found {
+		return 4
+	}
+	if _, decimals, found := strings.Cut(mins, "."); found {
+		return len(decimals)
+	}
+	return 0
+}
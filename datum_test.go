@@ -0,0 +1,71 @@
+package nmea0183
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDatumTransforms(t *testing.T) {
+	// Greenwich from Chris Veness, Movable Type Scripts "Helmert transforms"
+	lat, long, err := FromWGS84(51.47788, -0.00147, "OSGB36")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNear(t, "osgb36 lat", lat, 51.4773, 1e-4)
+	expectNear(t, "osgb36 long", long, 0.0001, 1e-4)
+	lat, long, _ = ToWGS84(lat, long, "OGB")
+	expectNear(t, "wgs84 lat", lat, 51.47788, 1e-7)
+	expectNear(t, "wgs84 long", long, -0.00147, 1e-7)
+	if _, _, err := ToWGS84(0, 0, "XYZ"); err == nil {
+		t.Error("expected error for unknown datum")
+	}
+}
+
+func TestParseDTM(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Parse("$GPDTM,999,,0.0800,S,0.1200,E,0.0,W84*1B")
+	if nm.Get("dtm_datum") != "999" || nm.Get("dtm_lat_offset") != "-0.0800" || nm.Get("dtm_ref") != "W84" {
+		t.Errorf("dtm not parsed %v", nm.GetMap())
+	}
+	if f, _ := nm.Float("dtm_long_offset"); f != 0.12 {
+		t.Errorf("dtm long offset got %f", f)
+	}
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	if nm.Get("position") != "50° 47.3986'N, 000° 54.6007'W" || nm.Get("position_datum") != "999" {
+		t.Errorf("position should be recorded in local datum got %s %s", nm.Get("position"), nm.Get("position_datum"))
+	}
+
+	nm.NormaliseDatum(true)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	if nm.Get("position") != "50° 47.4786'N, 000° 54.7207'W" || nm.Get("position_datum") != "W84" {
+		t.Errorf("offsets not applied got %s %s", nm.Get("position"), nm.Get("position_datum"))
+	}
+}
+
+func TestNormaliseDatum(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.NormaliseDatum(true)
+	nm.Parse("$GPDTM,OGB,,0.0000,N,0.0000,E,0.0,W84*7E")
+	nm.Parse("$GPRMC,110910.59,A,5128.6418,N,00000.0090,E,0.08,0.19,150920,0.24,W,D,V*69")
+	lat, long, _ := nm.LatLongToFloat("position")
+	expectNear(t, "normalised lat", lat, 51.47788, 2e-6)
+	expectNear(t, "normalised long", long, -0.00147, 2e-6)
+	if nm.Get("position_datum") != "W84" {
+		t.Errorf("expected datum W84 got %s", nm.Get("position_datum"))
+	}
+
+	if err := nm.SetOutputDatum("OSGB36"); err != nil {
+		t.Fatal(err)
+	}
+	rmc, _ := nm.WriteSentence("GP", "RMC")
+	if !strings.HasPrefix(rmc, "$GPRMC,110910.59,A,5128.6418,N,00000.0090,E,") {
+		t.Errorf("position not written in OSGB36 got %s", rmc)
+	}
+	if nm.Get("position") == "51° 28.6418'N, 000° 00.0090'E" {
+		t.Error("writing in a datum must not change data")
+	}
+	dtm, err := nm.WriteDTM("GP")
+	if err != nil || !strings.HasPrefix(dtm, "$GPDTM,OGB,,0.0") || !strings.Contains(dtm, ",W84*") {
+		t.Errorf("dtm got %s %v", dtm, err)
+	}
+}
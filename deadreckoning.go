@@ -0,0 +1,123 @@
+package nmea0183
+
+import (
+	"fmt"
+	"time"
+)
+
+type drState struct {
+	useWater  bool  // heading and stw instead of tmg and sog
+	overwrite bool  // estimated position is also written to position
+	timeout   int64 // milliseconds without a position before the fix is taken as lost, 0 to wait for status V
+	known     bool  // a fix has been received
+	active    bool  // estimating
+	fix       int64 // time of the last fix in milliseconds
+	last      int64 // time of the last estimate in milliseconds
+	lat, long float64
+}
+
+// Set dead reckoning preferences:
+// useWater = true to estimate from heading and stw, false for the last tmg and sog
+// timeout is the time without a position update after which the fix is taken as lost, 0 to only
+// estimate when RMC status is V
+// overwrite = true to also write the estimated position to position with faa_mode E
+func (h *Handle) DeadReckoningPreferences(useWater bool, timeout time.Duration, overwrite bool) {
+	h.dr.useWater = useWater
+	h.dr.timeout = timeout.Milliseconds()
+	h.dr.overwrite = overwrite
+}
+
+// Keeps dr_position up to date as sentences are parsed.  dr_position is the position while there is
+// a good fix and is estimated by DeadReckon from the last good fix once it is lost.  dr_active
+// is A while estimating and V when the fix returns.  Positions derived by the handle, including
+// the estimate written to position by overwrite, are never taken as a fix.
+func (h *Handle) AttachDeadReckoning() {
+	h.onParsedUpdate(func(results map[string]string) {
+		status, ok := results["status"]
+		if !ok {
+			status = h.data["status"]
+		}
+		if pos, ok := results["position"]; ok && len(pos) > 0 && status != "V" {
+			lat, long, err := LatLongToFloat(pos)
+			if err != nil {
+				return
+			}
+			timeNow := h.timeNow()
+			h.dr.known, h.dr.active = true, false
+			h.dr.fix, h.dr.last = timeNow, timeNow
+			h.dr.lat, h.dr.long = lat, long
+			h.setVars(map[string]string{"dr_position": pos, "dr_active": "V"})
+			return
+		}
+		lost := results["status"] == "V" || h.dr.active
+		if h.dr.timeout > 0 && h.timeNow()-h.dr.fix > h.dr.timeout {
+			lost = true
+		}
+		if h.dr.known && lost {
+			h.DeadReckon()
+		}
+	})
+}
+
+// Advances the estimated position from the last fix or estimate using the true heading and stw or
+// the tmg and sog set by DeadReckoningPreferences and the time since.  Sets dr_position and
+// dr_active to A and if overwrite is set position and faa_mode E.
+func (h *Handle) DeadReckon() error {
+	if !h.dr.known {
+		return fmt.Errorf("no fix to dead reckon from")
+	}
+	var course, speed float64
+	var err error
+	if h.dr.useWater {
+		if course, err = h.trueHeading(true); err != nil {
+			return err
+		}
+		if speed, err = h.Float("stw"); err != nil {
+			return err
+		}
+	} else {
+		if course, err = h.Float("tmg"); err != nil {
+			return err
+		}
+		if speed, err = h.Float("sog"); err != nil {
+			return err
+		}
+	}
+	timeNow := h.timeNow()
+	hours := float64(timeNow-h.dr.last) / float64(time.Hour.Milliseconds())
+	if hours > 0 {
+		h.dr.lat, h.dr.long = RhumbDestination(h.dr.lat, h.dr.long, course, speed*hours)
+	}
+	h.dr.last = timeNow
+	h.dr.active = true
+
+	pos := positionStr(h.dr.lat, h.dr.long)
+	values := map[string]string{"dr_position": pos, "dr_active": "A"}
+	if h.dr.overwrite {
+		values["position"] = pos
+		values["faa_mode"] = "E"
+	}
+	h.setVars(values)
+	return nil
+}
+
+// Returns RMC and GLL sentences with dr_position, mode E and, as NMEA requires for mode E,
+// status V.  The time and date are the current time when estimating.
+func (h *Handle) WriteDeadReckoning(manCode string) ([]string, error) {
+	values := map[string]string{"position": h.data["dr_position"], "faa_mode": "E", "status": "V"}
+	if h.dr.active {
+		t := time.UnixMilli(h.timeNow()).UTC()
+		values["fix_time"] = t.Format("15:04:05.00")
+		values["fix_date"] = t.Format("2006-01-02")
+	}
+	var sentences []string
+	var firstErr error
+	for _, name := range []string{"RMC", "GLL"} {
+		s, err := h.WriteSentenceWith(manCode, name, values)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+		sentences = append(sentences, s)
+	}
+	return sentences, firstErr
+}
@@ -0,0 +1,74 @@
+package nmea0183
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadReckoning(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.DeadReckoningPreferences(true, 0, true)
+	nm.AttachDeadReckoning()
+	if err := nm.DeadReckon(); err == nil {
+		t.Error("expected error with no fix")
+	}
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Update(map[string]string{"hdt": "90.0°T", "stw": "6.0"})
+	if nm.Get("dr_position") != nm.Get("position") || nm.Get("dr_active") != "V" {
+		t.Errorf("dr_position should follow the fix got %s %s", nm.Get("dr_position"), nm.Get("dr_active"))
+	}
+
+	// fix lost for a minute at 6 knots east
+	nm.Parse("$GPRMC,111010.59,V,,,,,,,150920,,,N,V*04")
+	if nm.Get("dr_position") != "50° 47.3986'N, 000° 54.4426'W" || nm.Get("dr_active") != "A" {
+		t.Errorf("dead reckoning got %s %s", nm.Get("dr_position"), nm.Get("dr_active"))
+	}
+	if nm.Get("position") != nm.Get("dr_position") || nm.Get("faa_mode") != "E" {
+		t.Errorf("position should be overwritten got %s %s", nm.Get("position"), nm.Get("faa_mode"))
+	}
+	sentences, err := nm.WriteDeadReckoning("GP")
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(sentences[0], "$GPRMC,111010.59,V,5047.3986,N,00054.4426,W,") || !strings.Contains(sentences[0], ",150920,") ||
+		!strings.HasPrefix(sentences[1], "$GPGLL,5047.3986,N,00054.4426,W,111010.59,V,E*") {
+		t.Errorf("dead reckoning sentences got %v", sentences)
+	}
+
+	nm.Parse("$GPRMC,111110.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*7C")
+	if nm.Get("dr_active") != "V" || nm.Get("dr_position") != "50° 47.3986'N, 000° 54.6007'W" {
+		t.Errorf("dead reckoning should stop when the fix returns got %s %s", nm.Get("dr_active"), nm.Get("dr_position"))
+	}
+}
+
+func TestDeadReckoningFromFixesOnly(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AttachDeadReckoning()
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.setVars(map[string]string{"position": "50° 48.0000'N, 000° 54.0000'W"})
+	if nm.Get("dr_position") != "50° 47.3986'N, 000° 54.6007'W" {
+		t.Errorf("a derived position should not be taken as a fix got %s", nm.Get("dr_position"))
+	}
+}
+
+func TestDeadReckoningTimeout(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.DeadReckoningPreferences(false, 10*time.Second, false)
+	nm.AttachDeadReckoning()
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Update(map[string]string{"sog": "6.0", "tmg": "90.0", "datetime": "2020-09-15T11:09:15.59+00:00"})
+	if nm.Get("dr_active") != "V" {
+		t.Error("should not dead reckon before the timeout")
+	}
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:10:10.59+00:00"})
+	if nm.Get("dr_position") != "50° 47.3986'N, 000° 54.4426'W" || nm.Get("dr_active") != "A" {
+		t.Errorf("dead reckoning after timeout got %s %s", nm.Get("dr_position"), nm.Get("dr_active"))
+	}
+	if nm.Get("position") != "50° 47.3986'N, 000° 54.6007'W" {
+		t.Errorf("position should not be overwritten got %s", nm.Get("position"))
+	}
+}
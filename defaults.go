@@ -4,6 +4,7 @@ func DefaultSentences() *Sentences {
 	var defaults Sentences
 	defaults.formats = GetDefaultFormats()
 	defaults.variables = GetDefaultVars()
+	defaults.validity = GetDefaultValidity()
 	return &defaults
 }
 
@@ -33,7 +34,7 @@ func GetDefaultVars() map[string]string {
 		"long":     "long,WE",        // formated longitude
 		"position": "lat,NS,long,WE", //formated lat, long
 		"sog":      "x.x",            // Speed Over Ground  float knots
-		"tmg":      "x.x",            // Track Made Good
+		"tmg":      "x.x°",           // Track Made Good
 		"fix_date": "ddmmyy",
 		"mag_var":  "x.x,w", // Mag Var E positive, W negative
 		"day":      "DD_day",
@@ -52,6 +53,64 @@ func GetDefaultVars() map[string]string {
 		"stw":      "x.x",       // Speed Through Water float knots
 		"dw":       "x.x",       // Water distance since reset float knots
 
+		"wpl_position": "lat,NS,long,WE", // Waypoint location
+		"wpl_id":       "c--c",           // Waypoint ID of wpl_position
+		"wpt_position": "lat,NS,long,WE", // Destination waypoint of the active leg
+		"origin_id":    "c--c",           // Origin waypoint ID of the active leg
+		"rte_count":    "x",              // Total number of RTE sentences for the route
+		"rte_num":      "x",              // Number of this RTE sentence
+		"rte_type":     "A",              // c = complete route, w = working route starting at the active leg
+		"rte_id":       "c--c",           // Route ID
+		"rte_waypts":   "c--c,...",       // Waypoint IDs separated by commas
+
+		"dtw":       "x.x",                           // Distance to destination waypoint Nm
+		"btw":       "x.x°",                          // Bearing to destination waypoint True
+		"vmg":       "x.x",                           // Velocity made good towards destination knots
+		"rmb_xte":   "x.x,R",                         // Cross Track Error as xte but units of Nm are not in the sentence
+		"bwc_mag":   "x.x,T",                         // Bearing to destination waypoint Magnetic
+		"bwc_range": "x.x,N",                         // Distance to destination waypoint Nm
+		"eta":       "plan_hhmmss,day,month,year,tz", // Estimated time of arrival at destination waypoint
+
+		"dtm_datum":       "c--c",  // Local datum W84, W72, S85, P90, 999 user defined or IHO code eg OGB (OSGB36), EUR (ED50)
+		"dtm_subdivision": "c--c",  // Local datum subdivision code
+		"dtm_lat_offset":  "x.x,s", // Lat offset of local datum from reference datum minutes, S negative
+		"dtm_long_offset": "x.x,w", // Long offset of local datum from reference datum minutes, W negative
+		"dtm_alt_offset":  "-x.x",  // Altitude offset metres
+		"dtm_ref":         "c--c",  // Reference datum normally W84
+
+		"wind_angle":  "x.x°",   // MWV wind angle from the bow 0 to 359
+		"wind_ref":    "A",      // MWV R relative (apparent) or T theoretical (true)
+		"wind_speed":  "x.x",    // MWV wind speed in wind_units
+		"wind_units":  "A",      // K km/h, M m/s, N knots, S statute mph
+		"wind_status": "A",      // A valid
+		"hdt":         "x.x,T",  // Heading True
+		"twa":         "x.x°,l", // True wind angle from the bow, L negative
+		"tws":         "x.x,N",  // True wind speed knots
+		"tws_ms":      "x.x,M",  // True wind speed m/s
+		"tws_kmh":     "x.x,K",  // True wind speed km/h
+		"twd":         "x.x,T",  // True wind direction True
+		"twd_mag":     "x.x,T",  // True wind direction Magnetic
+		"gws":         "x.x",    // Ground wind speed knots
+		"gwd":         "x.x,T",  // Ground wind direction True
+
+		"set":     "x.x,T", // Direction the current flows towards True
+		"set_mag": "x.x,T", // Direction the current flows towards Magnetic
+		"drift":   "x.x,N", // Current speed knots
+
+		"model_var": "x.x,w", // Magnetic variation from the World Magnetic Model E positive, W negative
+
+		"hdg_sensor":       "x.x°",  // Compass (sensor) heading before deviation
+		"hdg_dev":          "x.x,w", // Deviation E positive, W negative
+		"hdg_var":          "x.x,w", // Variation given by HDG E positive, W negative
+		"variation":        "x.x,w", // Variation used for headings from HDG, RMC or the magnetic model
+		"variation_source": "c--c",  // HDG, RMC or model
+
+		"dr_position": "lat,NS,long,WE", // Dead reckoning position, the position while there is a fix
+		"dr_active":   "A",              // A while estimating from the last fix, V while there is a fix
+
+		"position_smooth": "lat,NS,long,WE", // Filtered position
+		"sog_smooth":      "x.x",            // Filtered speed over ground knots
+		"tmg_smooth":      "x.x",            // Filtered track made good
 	}
 
 	return vars
@@ -65,11 +124,23 @@ func GetDefaultFormats() map[string][]string {
 		"apb": {"ap_status", "ap_loran", "xte", "arrived_circle", "passed_waypt", "bearing_origin_to_waypt", "waypt_id", "bearing_position_to_waypt", "hts", "ap_mode"},
 		"rmc": {"fix_time", "status", "position", "sog", "tmg", "fix_date", "mag_var", "faa_mode", "nav_status"},
 		"zda": {"datetime"},
-		"hdg": {"n/a", "n/a", "n/a", "mag_var"},
+		"hdg": {"hdg_sensor", "hdg_dev", "hdg_var"},
 		"hdm": {"hdm"},
 		"dpt": {"dbt", "toff"},
 		"vhm": {"n/a", "n/a", "n/a", "n/a", "stw"},
 		"vlw": {"n/a", "n/a", "wd"},
+		"wpl": {"wpl_position", "wpl_id"},
+		"rte": {"rte_count", "rte_num", "rte_type", "rte_id", "rte_waypts"},
+		"rmb": {"ap_status", "rmb_xte", "origin_id", "did", "wpt_position", "dtw", "btw", "vmg", "arrived_circle", "faa_mode"},
+		"bwc": {"fix_time", "wpt_position", "bearing_position_to_waypt", "bwc_mag", "bwc_range", "did", "faa_mode"},
+		"xte": {"ap_status", "ap_loran", "xte", "faa_mode"},
+		"mwv": {"wind_angle", "wind_ref", "wind_speed", "wind_units", "wind_status"},
+		"hdt": {"hdt"},
+		"mwd": {"twd", "twd_mag", "tws", "tws_ms"},
+		"vwt": {"twa", "tws", "tws_ms", "tws_kmh"},
+		"vdr": {"set", "set_mag", "drift"},
+		"gll": {"position", "fix_time", "status", "faa_mode"},
+		"dtm": {"dtm_datum", "dtm_subdivision", "dtm_lat_offset", "dtm_long_offset", "dtm_alt_offset", "dtm_ref"},
 	}
 
 	return formats
@@ -0,0 +1,63 @@
+package nmea0183
+
+type derivedState struct {
+	merging   bool                // merging values set by setVars
+	notifying bool                // calling the update functions
+	derived   bool                // calling the update functions with derived values
+	pending   []map[string]string // derived values waiting for the update functions
+}
+
+// calls the update functions with results and then with the values derived by them in turn,
+// derived values set while the functions are called are queued so that they are not re-entered
+func (h *Handle) notify(results map[string]string, derived bool) {
+	if h.derive.notifying {
+		h.derive.pending = append(h.derive.pending, results)
+		return
+	}
+	h.derive.notifying, h.derive.derived = true, derived
+	for _, f := range h.onUpdate {
+		f(results)
+	}
+	for len(h.derive.pending) > 0 {
+		derived := h.derive.pending[0]
+		h.derive.pending = h.derive.pending[1:]
+		h.derive.derived = true
+		for _, f := range h.onUpdate {
+			f(derived)
+		}
+	}
+	h.derive.derived, h.derive.notifying = false, false
+}
+
+// Returns true while the update functions are called with values derived by the handle eg
+// by TrueWind or a grid variable rather than parsed or given to Update
+func (h *Handle) Derived() bool {
+	return h.derive.derived
+}
+
+// Adds an update function which is not called with derived values, used by derivations so that
+// they are computed from parsed values and do not feed back into each other
+func (h *Handle) onParsedUpdate(f func(results map[string]string)) {
+	h.OnUpdate(func(results map[string]string) {
+		if !h.derive.derived {
+			f(results)
+		}
+	})
+}
+
+// Sets variables derived by the handle eg by TrueWind in data with the current time.  They are
+// merged as Update does, recorded by the encoder and the update functions are called with
+// Derived true.
+func (h *Handle) setVars(values map[string]string) {
+	h.setVarsAt(values, h.timeNow())
+}
+
+func (h *Handle) setVarsAt(values map[string]string, timeStamp int64) {
+	h.derive.merging = true
+	h.merge(values, timeStamp, nil)
+	h.derive.merging = false
+	if h.encoder != nil {
+		h.encoder.encodeDerived(h, values)
+	}
+	h.notify(values, true)
+}
@@ -0,0 +1,36 @@
+package nmea0183
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDerivedUpdates(t *testing.T) {
+	// derived values are given to the update functions marked as derived and are recorded
+	var buf bytes.Buffer
+	nm := DefaultSentences().MakeHandle()
+	nm.SetEncoder(NewEncoder(&buf))
+	nm.AddGridVariable("locator", "position", MaidenheadGrid, 6)
+	seen := map[string]bool{}
+	nm.OnUpdate(func(results map[string]string) {
+		if _, ok := results["locator"]; ok {
+			seen["locator"] = nm.Derived()
+		}
+		if _, ok := results["position"]; ok {
+			seen["position"] = nm.Derived()
+		}
+	})
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	if derived, ok := seen["locator"]; !ok || !derived || seen["position"] {
+		t.Errorf("update functions should see the derived locator got %v", seen)
+	}
+
+	nm2 := DefaultSentences().MakeHandle()
+	nm2.Preferences(0, false)
+	if count, err := nm2.ReadRecords(&buf); err != nil || count != 2 {
+		t.Fatalf("expected the sentence and derived records got %d %v", count, err)
+	}
+	if nm2.Get("locator") != nm.Get("locator") {
+		t.Errorf("derived record not replayed got %s", nm2.Get("locator"))
+	}
+}
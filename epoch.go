@@ -0,0 +1,110 @@
+package nmea0183
+
+import (
+	"time"
+)
+
+// The data set as it was when all the sentences of one receiver cycle had been merged
+type Epoch struct {
+	FixTime   string            // fix time of the cycle, empty if grouped by the quiet gap alone
+	Time      time.Time         // time stamp of the last sentence in the cycle
+	Variables []string          // variables updated during the cycle
+	Data      map[string]string // copy of the data set at the end of the cycle
+}
+
+type epochState struct {
+	enabled   bool
+	gap       int64 // milliseconds without a sentence which ends a cycle, 0 for none
+	started   bool
+	fixTime   string
+	last      int64
+	variables map[string]bool
+	complete  *Epoch
+	onEpoch   []func(Epoch)
+}
+
+// Groups sentences into receiver cycles (epochs) so that consumers can read position, sog and
+// the rest from the same fix.  A cycle ends when a sentence gives a different fix time or, if
+// gap > 0, when no sentence has arrived for gap measured by the processor clock in real time mode
+// or the message date for historic data.  Sentences without a time such as VTG and GSA join the
+// current cycle.  See Epoch and OnEpoch.
+// There is no timer: a cycle ended by the gap is only completed when the next sentence arrives,
+// call CloseEpoch eg from a ticker to complete it sooner.
+func (h *Handle) EpochPreferences(gap time.Duration) {
+	h.epoch.enabled = true
+	h.epoch.gap = gap.Milliseconds()
+}
+
+// Adds a function called with each completed epoch.  EpochPreferences must be set.  Each
+// function is given its own copy of the epoch.
+func (h *Handle) OnEpoch(f func(Epoch)) {
+	h.epoch.onEpoch = append(h.epoch.onEpoch, f)
+}
+
+// Returns a copy of the latest completed epoch, false if there is none yet
+func (h *Handle) Epoch() (Epoch, bool) {
+	if h.epoch.complete == nil {
+		return Epoch{}, false
+	}
+	return h.epoch.complete.copy(), true
+}
+
+// Returns a copy of the epoch which does not share its variables or data
+func (e *Epoch) copy() Epoch {
+	epoch := *e
+	epoch.Variables = append([]string(nil), e.Variables...)
+	epoch.Data = make(map[string]string, len(e.Data))
+	for k, v := range e.Data {
+		epoch.Data[k] = v
+	}
+	return epoch
+}
+
+// Completes the current epoch without waiting for the next, eg at the end of a log file
+func (h *Handle) CloseEpoch() {
+	e := &h.epoch
+	if !e.started {
+		return
+	}
+	epoch := Epoch{FixTime: e.fixTime, Time: time.UnixMilli(e.last).UTC(), Data: make(map[string]string, len(h.data))}
+	for k := range e.variables {
+		epoch.Variables = append(epoch.Variables, k)
+	}
+	for k, v := range h.data {
+		epoch.Data[k] = v
+	}
+	e.started, e.fixTime, e.variables = false, "", nil
+	e.complete = &epoch
+	for _, f := range e.onEpoch {
+		f(epoch.copy())
+	}
+}
+
+// Called before results are merged to complete the current epoch when they start a new one
+func (h *Handle) epochUpdate(results map[string]string, timeStamp int64) {
+	e := &h.epoch
+	fixTime := ""
+	for n, v := range results {
+		if tType, _ := getConversion(h.sentences.variables[n]); tType == "time" && len(v) > 0 {
+			fixTime = v
+			break
+		}
+	}
+	if e.started {
+		newFix := len(fixTime) > 0 && len(e.fixTime) > 0 && fixTime != e.fixTime
+		quiet := e.gap > 0 && timeStamp-e.last > e.gap
+		if newFix || quiet {
+			h.CloseEpoch()
+		}
+	}
+	if !e.started {
+		e.started, e.variables = true, make(map[string]bool)
+	}
+	if len(e.fixTime) == 0 {
+		e.fixTime = fixTime
+	}
+	e.last = timeStamp
+	for n := range results {
+		e.variables[n] = true
+	}
+}
@@ -0,0 +1,62 @@
+package nmea0183
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestEpochByFixTime(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.EpochPreferences(0)
+	var epochs []Epoch
+	nm.OnEpoch(func(e Epoch) { epochs = append(epochs, e) })
+
+	nm.Update(map[string]string{"fix_time": "11:00:00.00", "position": "50° 00.0000'N, 001° 00.0000'W", "sog": "5.0"})
+	nm.Update(map[string]string{"position": "50° 00.0000'N, 001° 00.0000'W", "fix_time": "11:00:00.00", "status": "A"})
+	nm.Update(map[string]string{"dbt": "10.0"})
+	if _, ok := nm.Epoch(); ok {
+		t.Error("epoch should not be complete until the next fix")
+	}
+	nm.Update(map[string]string{"fix_time": "11:00:01.00", "position": "50° 00.0100'N, 001° 00.0000'W", "sog": "6.0"})
+
+	e, ok := nm.Epoch()
+	if !ok || len(epochs) != 1 || e.FixTime != "11:00:00.00" {
+		t.Fatalf("epoch got %+v %v", e, ok)
+	}
+	// the epoch keeps the data of its own cycle
+	if e.Data["sog"] != "5.0" || e.Data["position"] != "50° 00.0000'N, 001° 00.0000'W" || e.Data["dbt"] != "10.0" {
+		t.Errorf("epoch data got %v", e.Data)
+	}
+	sort.Strings(e.Variables)
+	if len(e.Variables) != 5 || e.Variables[0] != "dbt" || e.Variables[4] != "status" {
+		t.Errorf("epoch variables got %v", e.Variables)
+	}
+	nm.CloseEpoch()
+	if e, _ := nm.Epoch(); e.FixTime != "11:00:01.00" || e.Data["sog"] != "6.0" || len(epochs) != 2 {
+		t.Errorf("closed epoch got %+v", e)
+	}
+
+	// epochs given out are copies
+	e, _ = nm.Epoch()
+	e.Data["sog"], e.Variables[0] = "9.9", "changed"
+	epochs[1].Data["sog"] = "8.8"
+	if e, _ := nm.Epoch(); e.Data["sog"] != "6.0" || e.Variables[0] == "changed" {
+		t.Errorf("epoch should not share data got %+v", e)
+	}
+}
+
+func TestEpochByGap(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.EpochPreferences(500 * time.Millisecond)
+	start := time.Date(2020, 9, 15, 11, 0, 0, 0, time.UTC)
+	for _, ms := range []int{0, 100, 200, 1000, 1100} {
+		nm.Update(map[string]string{"datetime": dateTimeStr(start.Add(time.Duration(ms) * time.Millisecond))})
+	}
+	e, ok := nm.Epoch()
+	if !ok || !e.Time.Equal(start.Add(200*time.Millisecond)) || e.FixTime != "" {
+		t.Errorf("epoch by gap got %+v %v", e, ok)
+	}
+}
@@ -0,0 +1,209 @@
+package nmea0183
+
+import (
+	"math"
+	"strconv"
+)
+
+// Filters used to smooth position, sog and tmg
+type FilterKind int
+
+const (
+	KalmanFilter    FilterKind = iota // constant velocity Kalman filter
+	AlphaBetaFilter                   // alpha-beta filter with gains from the same noise settings
+)
+
+const metresPerDegree = 60 * metresPerNm
+
+type filterState struct {
+	kind          FilterKind
+	processNoise  float64 // acceleration noise spectral density m²/s³
+	positionNoise float64 // standard deviation of a position m
+	speedNoise    float64 // standard deviation of sog m/s
+	started       bool
+	last          int64 // time of the last update in milliseconds
+	lat0, long0   float64
+	axes          [2]filterAxis // east, north
+}
+
+// position and velocity along one axis in metres and m/s with covariance
+type filterAxis struct {
+	p, v          float64
+	p00, p01, p11 float64
+}
+
+// Set the filter used by AttachFilter and its noise settings:
+// processNoise in m²/s³ is how quickly the boat's velocity is expected to change, larger follows faster
+// positionNoise in metres and speedNoise in knots are the standard deviation of the receiver's
+// position and sog.  Values <= 0 keep the defaults of 0.001, 5 and 0.3
+func (h *Handle) FilterPreferences(kind FilterKind, processNoise, positionNoise, speedNoise float64) {
+	h.filter.kind = kind
+	if processNoise > 0 {
+		h.filter.processNoise = processNoise
+	}
+	if positionNoise > 0 {
+		h.filter.positionNoise = positionNoise
+	}
+	if speedNoise > 0 {
+		h.filter.speedNoise = speedNoise * metresPerNm / 3600
+	}
+	h.filter.started = false
+}
+
+// Filters position, sog and tmg as they are parsed and stores the results in position_smooth,
+// sog_smooth and tmg_smooth.  Time is taken from the date and time of the sentence when given
+// so that live and replayed data are filtered the same.  Updates with status V or faa_mode
+// N, E or S are ignored and positions with faa_mode D, F or R are given more weight.  Values derived
+// by the handle eg by dead reckoning are not measurements and are ignored.
+func (h *Handle) AttachFilter() {
+	h.onParsedUpdate(h.filterUpdate)
+}
+
+func (h *Handle) filterUpdate(results map[string]string) {
+	pos, hasPos := results["position"]
+	hasPos = hasPos && len(pos) > 0
+	sog, errSog := strconv.ParseFloat(results["sog"], 64)
+	tmg, errTmg := strconv.ParseFloat(results["tmg"], 64)
+	hasVel := errSog == nil && errTmg == nil
+	if !hasPos && !hasVel {
+		return
+	}
+	status, ok := results["status"]
+	if !ok {
+		status = h.data["status"]
+	}
+	mode, ok := results["faa_mode"]
+	if !ok {
+		mode = h.data["faa_mode"]
+	}
+	quality := map[string]float64{"D": 0.5, "F": 0.2, "R": 0.1}[mode]
+	if quality == 0 {
+		quality = 1
+	}
+	if status == "V" || mode == "N" || mode == "E" || mode == "S" {
+		return
+	}
+
+	timeNow := h.timeNow()
+	if h.resultsDated {
+		timeNow = h.resultsDate.UnixMilli()
+	}
+	f := &h.filter
+
+	var lat, long float64
+	var err error
+	if hasPos {
+		if lat, long, err = LatLongToFloat(pos); err != nil {
+			hasPos = false
+		}
+	}
+	// start again after a long gap rather than predict across it
+	if f.started && timeNow-f.last > 5*60*1000 {
+		f.started = false
+	}
+	if !f.started {
+		if !hasPos {
+			return
+		}
+		*f = filterState{kind: f.kind, processNoise: f.processNoise, positionNoise: f.positionNoise, speedNoise: f.speedNoise,
+			started: true, last: timeNow, lat0: lat, long0: long}
+		for i := range f.axes {
+			f.axes[i] = filterAxis{p00: f.positionNoise * f.positionNoise, p11: 100}
+		}
+	}
+
+	dt := float64(timeNow-f.last) / 1000
+	if dt < 0 {
+		dt = 0
+	}
+	f.last = timeNow
+	for i := range f.axes {
+		f.axes[i].predict(dt, f.processNoise)
+	}
+	if hasPos {
+		east := (long - f.long0) * metresPerDegree * math.Cos(toRad(f.lat0))
+		north := (lat - f.lat0) * metresPerDegree
+		r := f.positionNoise * quality
+		for i, z := range []float64{east, north} {
+			f.axes[i].updatePosition(z, r*r, dt, f)
+		}
+	}
+	if hasVel {
+		speed := sog * metresPerNm / 3600
+		r := f.speedNoise * f.speedNoise
+		for i, z := range []float64{speed * math.Sin(toRad(tmg)), speed * math.Cos(toRad(tmg))} {
+			f.axes[i].updateVelocity(z, r, f)
+		}
+	}
+
+	smoothLat := f.lat0 + f.axes[1].p/metresPerDegree
+	smoothLong := f.long0 + f.axes[0].p/(metresPerDegree*math.Cos(toRad(f.lat0)))
+	ve, vn := f.axes[0].v, f.axes[1].v
+	decimals := 4
+	if hasPos {
+		decimals = minuteDecimals(pos)
+	}
+	latStr, longStr, _ := LatLongToStringFormat(smoothLat, smoothLong, DegreesDecimalMinutes, decimals)
+	h.setVars(map[string]string{
+		"position_smooth": latStr + ", " + longStr,
+		"sog_smooth":      strconv.FormatFloat(math.Hypot(ve, vn)*3600/metresPerNm, 'f', 2, 64),
+		"tmg_smooth":      strconv.FormatFloat(wrap360(toDeg(math.Atan2(ve, vn))), 'f', 1, 64),
+	})
+
+	// keep the origin near the boat so that the flat earth approximation holds
+	if math.Hypot(f.axes[0].p, f.axes[1].p) > 10*metresPerNm {
+		f.lat0, f.long0 = smoothLat, smoothLong
+		f.axes[0].p, f.axes[1].p = 0, 0
+	}
+}
+
+func (a *filterAxis) predict(dt, q float64) {
+	a.p += a.v * dt
+	a.p00 += dt*(2*a.p01+dt*a.p11) + q*dt*dt*dt/3
+	a.p01 += dt*a.p11 + q*dt*dt/2
+	a.p11 += q * dt
+}
+
+func (a *filterAxis) updatePosition(z, r, dt float64, f *filterState) {
+	residual := z - a.p
+	if f.kind == AlphaBetaFilter {
+		alpha, beta := alphaBeta(f.processNoise, r, dt)
+		a.p += alpha * residual
+		if dt > 0 {
+			a.v += beta / dt * residual
+		}
+		return
+	}
+	s := a.p00 + r
+	k0, k1 := a.p00/s, a.p01/s
+	a.p += k0 * residual
+	a.v += k1 * residual
+	a.p00, a.p01, a.p11 = (1-k0)*a.p00, (1-k0)*a.p01, a.p11-k1*a.p01
+}
+
+func (a *filterAxis) updateVelocity(z, r float64, f *filterState) {
+	residual := z - a.v
+	if f.kind == AlphaBetaFilter {
+		_, beta := alphaBeta(f.processNoise, r, 1)
+		a.v += beta * residual
+		return
+	}
+	s := a.p11 + r
+	k0, k1 := a.p01/s, a.p11/s
+	a.p += k0 * residual
+	a.v += k1 * residual
+	a.p00, a.p01, a.p11 = a.p00-k0*a.p01, (1-k1)*a.p01, (1-k1)*a.p11
+}
+
+// Steady state gains from the tracking index (Kalata) for process noise q, measurement variance r
+// and update interval dt
+func alphaBeta(q, r, dt float64) (float64, float64) {
+	if dt <= 0 {
+		dt = 1
+	}
+	λ := math.Sqrt(q*dt*dt*dt) / math.Sqrt(r)
+	rr := (4 + λ - math.Sqrt(8*λ+λ*λ)) / 4
+	alpha := 1 - rr*rr
+	beta := 2*(2-alpha) - 4*math.Sqrt(1-alpha)
+	return alpha, beta
+}
@@ -0,0 +1,100 @@
+package nmea0183
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Feeds seconds of 1 Hz fixes of a boat at speed knots on course with receiver noise and returns
+// the rms position error in metres of the raw and smoothed positions after the first minute
+func feedFilter(h *Handle, seconds int, speed, course float64) (float64, float64) {
+	rnd := rand.New(rand.NewSource(1))
+	start := time.Date(2020, 9, 15, 11, 0, 0, 0, time.UTC)
+	lat0, long0 := 50.79, -0.91
+	var rawErr, smoothErr float64
+	n := 0
+	for i := 0; i < seconds; i++ {
+		lat, long := RhumbDestination(lat0, long0, course, speed*float64(i)/3600)
+		noisyLat := lat + rnd.NormFloat64()*5/metresPerDegree
+		noisyLong := long + rnd.NormFloat64()*5/(metresPerDegree*math.Cos(toRad(lat)))
+		vn := speed*math.Cos(toRad(course)) + rnd.NormFloat64()*0.3
+		ve := speed*math.Sin(toRad(course)) + rnd.NormFloat64()*0.3
+		h.Update(map[string]string{
+			"datetime": dateTimeStr(start.Add(time.Duration(i) * time.Second)),
+			"status":   "A",
+			"faa_mode": "A",
+			"position": positionStr(noisyLat, noisyLong),
+			"sog":      strconv.FormatFloat(math.Hypot(ve, vn), 'f', 2, 64),
+			"tmg":      strconv.FormatFloat(wrap360(toDeg(math.Atan2(ve, vn))), 'f', 1, 64),
+		})
+		if i >= 60 {
+			sLat, sLong, _ := h.LatLongToFloat("position_smooth")
+			rawErr += math.Pow(Distance(lat, long, noisyLat, noisyLong)*metresPerNm, 2)
+			smoothErr += math.Pow(Distance(lat, long, sLat, sLong)*metresPerNm, 2)
+			n++
+		}
+	}
+	return math.Sqrt(rawErr / float64(n)), math.Sqrt(smoothErr / float64(n))
+}
+
+func TestFilterAtAnchor(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AttachFilter()
+	raw, smooth := feedFilter(nm, 300, 0, 0)
+	if smooth > raw/2 {
+		t.Errorf("smoothed position error %.2f m should be less than half the raw %.2f m", smooth, raw)
+	}
+	if sog, _ := nm.Float("sog_smooth"); sog > 0.15 {
+		t.Errorf("smoothed sog at anchor got %f", sog)
+	}
+
+	// a fix with status V is ignored
+	before := nm.Get("position_smooth")
+	nm.Update(map[string]string{"status": "V", "position": "51° 00.0000'N, 001° 00.0000'W"})
+	if nm.Get("position_smooth") != before {
+		t.Error("position with status V should be ignored")
+	}
+
+	// as is a derived position
+	nm.Update(map[string]string{"status": "A"})
+	nm.setVars(map[string]string{"position": "51° 00.0000'N, 001° 00.0000'W"})
+	if nm.Get("position_smooth") != before {
+		t.Error("derived position should be ignored")
+	}
+}
+
+func TestFilterMoving(t *testing.T) {
+	for _, kind := range []FilterKind{KalmanFilter, AlphaBetaFilter} {
+		nm := DefaultSentences().MakeHandle()
+		nm.Preferences(0, false)
+		nm.FilterPreferences(kind, 0, 5, 0.3)
+		nm.AttachFilter()
+		raw, smooth := feedFilter(nm, 180, 6, 90)
+		if smooth > raw {
+			t.Errorf("filter %d smoothed position error %.2f m should be less than raw %.2f m", kind, smooth, raw)
+		}
+		sog, _ := nm.Float("sog_smooth")
+		tmg, _ := nm.Float("tmg_smooth")
+		expectNear(t, "smoothed sog", sog, 6, 0.2)
+		expectNear(t, "smoothed tmg", tmg, 90, 2)
+	}
+}
+
+func TestFilterLiveAndReplay(t *testing.T) {
+	live := DefaultSentences().MakeHandle()
+	live.AttachFilter()
+	replay := DefaultSentences().MakeHandle()
+	replay.Preferences(0, false)
+	replay.AttachFilter()
+	feedFilter(live, 30, 6, 45)
+	feedFilter(replay, 30, 6, 45)
+	for _, key := range []string{"position_smooth", "sog_smooth", "tmg_smooth"} {
+		if live.Get(key) != replay.Get(key) {
+			t.Errorf("%s differs live %s replay %s", key, live.Get(key), replay.Get(key))
+		}
+	}
+}
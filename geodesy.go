@@ -0,0 +1,335 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Geodesy functions take and return lat and long in decimal degrees with minus values for
+// South and West, as given by LatLongToFloat.  Distances are in nautical miles and
+// bearings in degrees true.  The Handle methods of the same names take variable names
+// holding positions in the same way as Handle.LatLongToFloat.
+
+const (
+	earthRadiusNm = 3440.065 // mean radius of the earth in nautical miles (6371 km)
+	metresPerNm   = 1852.0
+
+	wgs84A = 6378137.0         // WGS84 semi-major axis in metres
+	wgs84F = 1 / 298.257223563 // WGS84 flattening
+	wgs84B = wgs84A * (1 - wgs84F)
+)
+
+func toRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func wrap360(deg float64) float64 {
+	return math.Mod(math.Mod(deg, 360)+360, 360)
+}
+
+func wrap180(deg float64) float64 {
+	return math.Mod(math.Mod(deg+180, 360)+360, 360) - 180
+}
+
+// Returns the great circle distance between two points on a sphere using the haversine formula
+func Distance(lat1, long1, lat2, long2 float64) float64 {
+	φ1 := toRad(lat1)
+	φ2 := toRad(lat2)
+	Δφ := φ2 - φ1
+	Δλ := toRad(long2 - long1)
+	a := math.Sin(Δφ/2)*math.Sin(Δφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(Δλ/2)*math.Sin(Δλ/2)
+	return 2 * earthRadiusNm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Returns the initial great circle bearing from the first point to the second
+func Bearing(lat1, long1, lat2, long2 float64) float64 {
+	φ1 := toRad(lat1)
+	φ2 := toRad(lat2)
+	Δλ := toRad(long2 - long1)
+	y := math.Sin(Δλ) * math.Cos(φ2)
+	x := math.Cos(φ1)*math.Sin(φ2) - math.Sin(φ1)*math.Cos(φ2)*math.Cos(Δλ)
+	return wrap360(toDeg(math.Atan2(y, x)))
+}
+
+// Returns the bearing on arrival at the second point when following the great circle from the first
+func FinalBearing(lat1, long1, lat2, long2 float64) float64 {
+	return wrap360(Bearing(lat2, long2, lat1, long1) + 180)
+}
+
+// Returns the distance, initial bearing and final bearing between two points on the WGS84
+// ellipsoid using Vincenty's inverse formula.  Accurate to within 0.5 mm but returns an
+// error if the formula fails to converge which can happen for nearly antipodal points.
+func VincentyDistance(lat1, long1, lat2, long2 float64) (float64, float64, float64, error) {
+	L := toRad(long2 - long1)
+	tanU1 := (1 - wgs84F) * math.Tan(toRad(lat1))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	tanU2 := (1 - wgs84F) * math.Tan(toRad(lat2))
+	cosU2 := 1 / math.Sqrt(1+tanU2*tanU2)
+	sinU2 := tanU2 * cosU2
+
+	λ := L
+	var sinλ, cosλ, sinσ, cosσ, σ, cosSqα, cos2σM float64
+	converged := false
+	for i := 0; i < 1000; i++ {
+		sinλ = math.Sin(λ)
+		cosλ = math.Cos(λ)
+		sinSqσ := (cosU2*sinλ)*(cosU2*sinλ) + (cosU1*sinU2-sinU1*cosU2*cosλ)*(cosU1*sinU2-sinU1*cosU2*cosλ)
+		sinσ = math.Sqrt(sinSqσ)
+		if sinσ == 0 {
+			return 0, 0, 0, nil // coincident points
+		}
+		cosσ = sinU1*sinU2 + cosU1*cosU2*cosλ
+		σ = math.Atan2(sinσ, cosσ)
+		sinα := cosU1 * cosU2 * sinλ / sinσ
+		cosSqα = 1 - sinα*sinα
+		cos2σM = 0 // on the equator
+		if cosSqα != 0 {
+			cos2σM = cosσ - 2*sinU1*sinU2/cosSqα
+		}
+		C := wgs84F / 16 * cosSqα * (4 + wgs84F*(4-3*cosSqα))
+		λPrev := λ
+		λ = L + (1-C)*wgs84F*sinα*(σ+C*sinσ*(cos2σM+C*cosσ*(-1+2*cos2σM*cos2σM)))
+		if math.Abs(λ-λPrev) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return 0, 0, 0, fmt.Errorf("vincenty formula failed to converge")
+	}
+
+	uSq := cosSqα * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	Δσ := B * sinσ * (cos2σM + B/4*(cosσ*(-1+2*cos2σM*cos2σM)-B/6*cos2σM*(-3+4*sinσ*sinσ)*(-3+4*cos2σM*cos2σM)))
+	s := wgs84B * A * (σ - Δσ)
+
+	α1 := math.Atan2(cosU2*sinλ, cosU1*sinU2-sinU1*cosU2*cosλ)
+	α2 := math.Atan2(cosU1*sinλ, -sinU1*cosU2+cosU1*sinU2*cosλ)
+	return s / metresPerNm, wrap360(toDeg(α1)), wrap360(toDeg(α2)), nil
+}
+
+// Returns the rhumb line distance between two points
+func RhumbDistance(lat1, long1, lat2, long2 float64) float64 {
+	φ1 := toRad(lat1)
+	φ2 := toRad(lat2)
+	Δφ := φ2 - φ1
+	Δλ := toRad(math.Abs(wrap180(long2 - long1)))
+	Δψ := math.Log(math.Tan(math.Pi/4+φ2/2) / math.Tan(math.Pi/4+φ1/2))
+	q := math.Cos(φ1)
+	if math.Abs(Δψ) > 1e-12 {
+		q = Δφ / Δψ
+	}
+	return math.Sqrt(Δφ*Δφ+q*q*Δλ*Δλ) * earthRadiusNm
+}
+
+// Returns the constant rhumb line bearing from the first point to the second
+func RhumbBearing(lat1, long1, lat2, long2 float64) float64 {
+	φ1 := toRad(lat1)
+	φ2 := toRad(lat2)
+	Δλ := toRad(wrap180(long2 - long1))
+	Δψ := math.Log(math.Tan(math.Pi/4+φ2/2) / math.Tan(math.Pi/4+φ1/2))
+	return wrap360(toDeg(math.Atan2(Δλ, Δψ)))
+}
+
+// Returns the point reached by travelling distance from a point along a great circle
+// starting on the given bearing
+func Destination(lat, long, bearing, distance float64) (float64, float64) {
+	φ1 := toRad(lat)
+	λ1 := toRad(long)
+	θ := toRad(bearing)
+	δ := distance / earthRadiusNm
+	φ2 := math.Asin(math.Sin(φ1)*math.Cos(δ) + math.Cos(φ1)*math.Sin(δ)*math.Cos(θ))
+	λ2 := λ1 + math.Atan2(math.Sin(θ)*math.Sin(δ)*math.Cos(φ1), math.Cos(δ)-math.Sin(φ1)*math.Sin(φ2))
+	return toDeg(φ2), wrap180(toDeg(λ2))
+}
+
+// Returns the point reached by travelling distance from a point along a rhumb line of the given bearing
+func RhumbDestination(lat, long, bearing, distance float64) (float64, float64) {
+	φ1 := toRad(lat)
+	θ := toRad(bearing)
+	δ := distance / earthRadiusNm
+	Δφ := δ * math.Cos(θ)
+	φ2 := φ1 + Δφ
+	if math.Abs(φ2) > math.Pi/2 { // passed a pole
+		if φ2 > 0 {
+			φ2 = math.Pi - φ2
+		} else {
+			φ2 = -math.Pi - φ2
+		}
+	}
+	Δψ := math.Log(math.Tan(φ2/2+math.Pi/4) / math.Tan(φ1/2+math.Pi/4))
+	q := math.Cos(φ1)
+	if math.Abs(Δψ) > 1e-12 {
+		q = Δφ / Δψ
+	}
+	Δλ := δ * math.Sin(θ) / q
+	return toDeg(φ2), wrap180(long + toDeg(Δλ))
+}
+
+// Returns the distance of a point from the great circle path from start to end,
+// positive when the point is to the right of the path
+func CrossTrackDistance(lat, long, startLat, startLong, endLat, endLong float64) float64 {
+	δ13 := Distance(startLat, startLong, lat, long) / earthRadiusNm
+	θ13 := toRad(Bearing(startLat, startLong, lat, long))
+	θ12 := toRad(Bearing(startLat, startLong, endLat, endLong))
+	return math.Asin(math.Sin(δ13)*math.Sin(θ13-θ12)) * earthRadiusNm
+}
+
+// Returns the distance from start to the point on the great circle path from start to end
+// closest to a point, negative if the closest point is behind the start
+func AlongTrackDistance(lat, long, startLat, startLong, endLat, endLong float64) float64 {
+	δ13 := Distance(startLat, startLong, lat, long) / earthRadiusNm
+	θ13 := toRad(Bearing(startLat, startLong, lat, long))
+	θ12 := toRad(Bearing(startLat, startLong, endLat, endLong))
+	δxt := math.Asin(math.Sin(δ13) * math.Sin(θ13-θ12))
+	δat := math.Acos(math.Max(-1, math.Min(1, math.Cos(δ13)/math.Cos(δxt))))
+	if math.Cos(θ13-θ12) < 0 {
+		δat = -δat
+	}
+	return δat * earthRadiusNm
+}
+
+// Returns the point half way along the great circle path between two points
+func Midpoint(lat1, long1, lat2, long2 float64) (float64, float64) {
+	return Interpolate(lat1, long1, lat2, long2, 0.5)
+}
+
+// Returns the point at fraction of the way along the great circle path between two points,
+// 0 giving the first point and 1 the second
+func Interpolate(lat1, long1, lat2, long2, fraction float64) (float64, float64) {
+	φ1, λ1 := toRad(lat1), toRad(long1)
+	φ2, λ2 := toRad(lat2), toRad(long2)
+	δ := Distance(lat1, long1, lat2, long2) / earthRadiusNm
+	if δ == 0 {
+		return lat1, long1
+	}
+	a := math.Sin((1-fraction)*δ) / math.Sin(δ)
+	b := math.Sin(fraction*δ) / math.Sin(δ)
+	x := a*math.Cos(φ1)*math.Cos(λ1) + b*math.Cos(φ2)*math.Cos(λ2)
+	y := a*math.Cos(φ1)*math.Sin(λ1) + b*math.Cos(φ2)*math.Sin(λ2)
+	z := a*math.Sin(φ1) + b*math.Sin(φ2)
+	return toDeg(math.Atan2(z, math.Sqrt(x*x+y*y))), toDeg(math.Atan2(y, x))
+}
+
+// Returns a position held in data as floats.  The key is either the name of a position
+// variable or the names of a lat and a long variable separated by a comma eg "lat,long"
+func (h *Handle) positionVar(key string) (float64, float64, error) {
+	if latKey, longKey, found := strings.Cut(key, ","); found {
+		if len(h.data[latKey]) == 0 || len(h.data[longKey]) == 0 {
+			return 0, 0, fmt.Errorf("no value for %s", key)
+		}
+		return h.LatLongToFloat(latKey, longKey)
+	}
+	return h.positionFloat(key)
+}
+
+func (h *Handle) twoPositions(from, to string) (float64, float64, float64, float64, error) {
+	lat1, long1, err := h.positionVar(from)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	lat2, long2, err := h.positionVar(to)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	return lat1, long1, lat2, long2, nil
+}
+
+// As Distance but from and to are the names of position variables, or of lat and long
+// variables separated by a comma eg "lat,long"
+func (h *Handle) Distance(from, to string) (float64, error) {
+	lat1, long1, lat2, long2, err := h.twoPositions(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return Distance(lat1, long1, lat2, long2), nil
+}
+
+// As Bearing but from and to are the names of position variables
+func (h *Handle) Bearing(from, to string) (float64, error) {
+	lat1, long1, lat2, long2, err := h.twoPositions(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return Bearing(lat1, long1, lat2, long2), nil
+}
+
+// As VincentyDistance but from and to are the names of position variables
+func (h *Handle) VincentyDistance(from, to string) (float64, float64, float64, error) {
+	lat1, long1, lat2, long2, err := h.twoPositions(from, to)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return VincentyDistance(lat1, long1, lat2, long2)
+}
+
+// As RhumbDistance but from and to are the names of position variables
+func (h *Handle) RhumbDistance(from, to string) (float64, error) {
+	lat1, long1, lat2, long2, err := h.twoPositions(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return RhumbDistance(lat1, long1, lat2, long2), nil
+}
+
+// As RhumbBearing but from and to are the names of position variables
+func (h *Handle) RhumbBearing(from, to string) (float64, error) {
+	lat1, long1, lat2, long2, err := h.twoPositions(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return RhumbBearing(lat1, long1, lat2, long2), nil
+}
+
+// As Destination but from is the name of a position variable
+func (h *Handle) Destination(from string, bearing, distance float64) (float64, float64, error) {
+	lat, long, err := h.positionVar(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat, long = Destination(lat, long, bearing, distance)
+	return lat, long, nil
+}
+
+// As CrossTrackDistance but point, start and end are the names of position variables
+func (h *Handle) CrossTrackDistance(point, start, end string) (float64, error) {
+	lat, long, err := h.positionVar(point)
+	if err != nil {
+		return 0, err
+	}
+	startLat, startLong, endLat, endLong, err := h.twoPositions(start, end)
+	if err != nil {
+		return 0, err
+	}
+	return CrossTrackDistance(lat, long, startLat, startLong, endLat, endLong), nil
+}
+
+// As AlongTrackDistance but point, start and end are the names of position variables
+func (h *Handle) AlongTrackDistance(point, start, end string) (float64, error) {
+	lat, long, err := h.positionVar(point)
+	if err != nil {
+		return 0, err
+	}
+	startLat, startLong, endLat, endLong, err := h.twoPositions(start, end)
+	if err != nil {
+		return 0, err
+	}
+	return AlongTrackDistance(lat, long, startLat, startLong, endLat, endLong), nil
+}
+
+// As Interpolate but from and to are the names of position variables, use 0.5 for the midpoint
+func (h *Handle) Interpolate(from, to string, fraction float64) (float64, float64, error) {
+	lat1, long1, lat2, long2, err := h.twoPositions(from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat, long := Interpolate(lat1, long1, lat2, long2, fraction)
+	return lat, long, nil
+}
@@ -0,0 +1,97 @@
+package nmea0183
+
+import (
+	"math"
+	"testing"
+)
+
+func dms(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
+
+func expectNear(t *testing.T, name string, got, expected, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-expected) > tolerance {
+		t.Errorf("%s expected %f got %f", name, expected, got)
+	}
+}
+
+// Reference values from Chris Veness, Movable Type Scripts "Calculate distance, bearing
+// and more between Latitude/Longitude points" using a 6371 km radius
+func TestGreatCircle(t *testing.T) {
+	lat1, long1 := dms(50, 3, 59), -dms(5, 42, 53)
+	lat2, long2 := dms(58, 38, 38), -dms(3, 4, 12)
+
+	expectNear(t, "distance km", Distance(lat1, long1, lat2, long2)*1.852, 968.9, 0.1)
+	expectNear(t, "initial bearing", Bearing(lat1, long1, lat2, long2), dms(9, 7, 11), 1.0/3600)
+	expectNear(t, "final bearing", FinalBearing(lat1, long1, lat2, long2), dms(11, 16, 31), 1.0/3600)
+
+	lat, long := Midpoint(lat1, long1, lat2, long2)
+	expectNear(t, "midpoint lat", lat, dms(54, 21, 44), 1.0/3600)
+	expectNear(t, "midpoint long", long, -dms(4, 31, 50), 1.0/3600)
+
+	lat, long = Destination(dms(53, 19, 14), -dms(1, 43, 47), dms(96, 1, 18), 124.8/1.852)
+	expectNear(t, "destination lat", lat, dms(53, 11, 18), 1.0/3600)
+	expectNear(t, "destination long", long, dms(0, 8, 0), 1.0/3600)
+}
+
+func TestRhumbLine(t *testing.T) {
+	lat1, long1 := dms(50, 21, 59), -dms(4, 8, 2)
+	lat2, long2 := dms(42, 21, 4), -dms(71, 2, 27)
+
+	expectNear(t, "rhumb distance km", RhumbDistance(lat1, long1, lat2, long2)*1.852, 5198, 1)
+	expectNear(t, "rhumb bearing", RhumbBearing(lat1, long1, lat2, long2), dms(260, 7, 38), 1.0/3600)
+
+	lat, long := RhumbDestination(dms(51, 7, 32), dms(1, 20, 17), dms(116, 38, 10), 40.23/1.852)
+	expectNear(t, "rhumb destination lat", lat, dms(50, 57, 48), 1.0/3600)
+	expectNear(t, "rhumb destination long", long, dms(1, 51, 9), 1.0/3600)
+}
+
+// Flinders Peak to Buninyong, the example from T Vincenty's 1975 paper as used by
+// Geoscience Australia
+func TestVincenty(t *testing.T) {
+	lat1, long1 := -dms(37, 57, 3.72030), dms(144, 25, 29.52440)
+	lat2, long2 := -dms(37, 39, 10.15610), dms(143, 55, 35.38390)
+
+	dist, initial, final, err := VincentyDistance(lat1, long1, lat2, long2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNear(t, "vincenty metres", dist*1852, 54972.271, 0.001)
+	expectNear(t, "vincenty initial bearing", initial, dms(306, 52, 5.37), 0.01/3600)
+	expectNear(t, "vincenty final bearing", final, dms(307, 10, 25.07), 0.01/3600)
+}
+
+func TestTrackDistances(t *testing.T) {
+	// along the equator from 0 to 1 degree east, a point 0.5 degree north is left of track
+	halfDegree := earthRadiusNm * math.Pi / 360
+	xte := CrossTrackDistance(0.5, 0.5, 0, 0, 0, 1)
+	expectNear(t, "cross track", xte, -halfDegree, 1e-9)
+	expectNear(t, "along track", AlongTrackDistance(0.5, 0.5, 0, 0, 0, 1), halfDegree, 1e-6)
+	expectNear(t, "behind start", AlongTrackDistance(0, -0.5, 0, 0, 0, 1), -halfDegree, 1e-9)
+
+	lat, long := Interpolate(0, 0, 0, 1, 0.25)
+	expectNear(t, "interpolate lat", lat, 0, 1e-9)
+	expectNear(t, "interpolate long", long, 0.25, 1e-9)
+}
+
+func TestHandleGeodesy(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.LatLongToString(dms(50, 47, 0), -dms(1, 0, 0), "mark")
+	nm.LatLongToString(dms(50, 48, 0), -dms(1, 0, 0), "mark_lat", "mark_long")
+
+	dist, err := nm.Distance("position", "mark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNear(t, "handle distance", dist, 3.439, 0.001)
+	bearing, _ := nm.Bearing("position", "mark_lat,mark_long")
+	expectNear(t, "handle bearing", bearing, 280.03, 0.01)
+	if _, err := nm.Distance("position", "missing"); err == nil {
+		t.Error("expected an error for a missing variable")
+	}
+}
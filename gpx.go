@@ -0,0 +1,192 @@
+package nmea0183
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const gpxNamespace = "https://github.com/martinmarsh/nmea0183"
+
+// Records a GPX track from the position variable as it is updated and waypoints
+// from WPL sentences and the waypoint ids used by APB, APA, AAM etc.
+type GPXRecorder struct {
+	h           *Handle
+	minInterval int64   // in milliseconds
+	minDistance float64 // in nautical miles
+	segments    [][]gpxPoint
+	newSegment  bool
+	last        gpxPoint
+	waypoints   []gpxPoint
+	waypointIDs []string
+	derived     bool // positions derived by the handle are also recorded
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+	Name string  `xml:"name,omitempty"`
+	Ext  *gpxExt `xml:"extensions,omitempty"`
+	ms   int64
+}
+
+type gpxExt struct {
+	SOG   string `xml:"nmea:sog,omitempty"`
+	COG   string `xml:"nmea:cog,omitempty"`
+	Depth string `xml:"nmea:depth,omitempty"`
+}
+
+type gpxFile struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	XmlnsNmea string     `xml:"xmlns:nmea,attr"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Track     *gpxTrack  `xml:"trk,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+// Makes a recorder for the data set of h, use Attach to start recording
+func NewGPXRecorder(h *Handle) *GPXRecorder {
+	return &GPXRecorder{h: h, newSegment: true}
+}
+
+// Only records a track point when at least minInterval has passed and the boat has moved
+// at least minDistance nautical miles since the last point.  Give 0 to record every update.
+func (g *GPXRecorder) Decimate(minInterval time.Duration, minDistance float64) {
+	g.minInterval = minInterval.Milliseconds()
+	g.minDistance = minDistance
+}
+
+// Also records positions derived by the handle eg by dead reckoning with overwrite.  By default
+// only positions parsed or given to Update are recorded.
+func (g *GPXRecorder) IncludeDerived(include bool) {
+	g.derived = include
+}
+
+// Records as sentences are parsed
+func (g *GPXRecorder) Attach() {
+	g.h.OnUpdate(g.Record)
+}
+
+// Records the results of an update.  A track point is added when results contain a valid
+// position, unless derived see IncludeDerived, and a new track segment is started when the fix
+// status is V.
+func (g *GPXRecorder) Record(results map[string]string) {
+	for _, key := range []string{"waypt_id", "did"} {
+		if id, ok := results[key]; ok && len(id) > 0 {
+			g.addWaypointID(id)
+		}
+	}
+	if lat, long, err := LatLongToFloat(results["wpl_position"]); err == nil {
+		g.addWaypoint(gpxPoint{Lat: lat, Lon: long, Name: results["wpl_id"]})
+	}
+
+	status, ok := results["status"]
+	if !ok {
+		status = g.h.data["status"]
+	}
+	if status == "V" {
+		g.newSegment = true
+		return
+	}
+	// a blank or invalid position is not a point
+	lat, long, err := LatLongToFloat(results["position"])
+	if err != nil || (g.h.Derived() && !g.derived) {
+		return
+	}
+	timeNow := g.h.timeNow()
+	if !g.newSegment && len(g.segments) > 0 {
+		if timeNow-g.last.ms < g.minInterval {
+			return
+		}
+		if g.minDistance > 0 && Distance(g.last.Lat, g.last.Lon, lat, long) < g.minDistance {
+			return
+		}
+	}
+	point := gpxPoint{
+		Lat:  lat,
+		Lon:  long,
+		Time: time.UnixMilli(timeNow).UTC().Format(time.RFC3339Nano),
+		ms:   timeNow,
+		Ext: &gpxExt{
+			SOG:   g.h.data["sog"],
+			COG:   g.h.data["tmg"],
+			Depth: g.h.data["dbt"],
+		},
+	}
+	if *point.Ext == (gpxExt{}) {
+		point.Ext = nil
+	}
+	if g.newSegment {
+		g.segments = append(g.segments, []gpxPoint{})
+		g.newSegment = false
+	}
+	g.segments[len(g.segments)-1] = append(g.segments[len(g.segments)-1], point)
+	g.last = point
+}
+
+func (g *GPXRecorder) addWaypointID(id string) {
+	for _, v := range g.waypointIDs {
+		if v == id {
+			return
+		}
+	}
+	g.waypointIDs = append(g.waypointIDs, id)
+}
+
+func (g *GPXRecorder) addWaypoint(wpt gpxPoint) {
+	for i, v := range g.waypoints {
+		if v.Name == wpt.Name {
+			g.waypoints[i] = wpt
+			return
+		}
+	}
+	g.waypoints = append(g.waypoints, wpt)
+}
+
+// Returns the ids of waypoints seen in the data, a waypoint is only written to the GPX
+// file if its position is known from a WPL sentence
+func (g *GPXRecorder) WaypointIDs() []string {
+	return g.waypointIDs
+}
+
+// Writes the recorded track and waypoints as a GPX 1.1 file.  sog, cog (tmg) and depth (dbt)
+// are written as extensions of each track point.
+func (g *GPXRecorder) Write(w io.Writer) error {
+	file := gpxFile{
+		Version:   "1.1",
+		Creator:   "nmea0183",
+		Xmlns:     "http://www.topografix.com/GPX/1/1",
+		XmlnsNmea: gpxNamespace,
+		Waypoints: g.waypoints,
+	}
+	if len(g.segments) > 0 {
+		file.Track = &gpxTrack{}
+		for _, seg := range g.segments {
+			if len(seg) > 0 {
+				file.Track.Segments = append(file.Track.Segments, gpxSegment{Points: seg})
+			}
+		}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("gpx could not be written: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(file); err != nil {
+		return fmt.Errorf("gpx could not be written: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package nmea0183
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGPXRecorder(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	gpx := NewGPXRecorder(nm)
+	gpx.Decimate(0, 0.001)
+	gpx.Attach()
+
+	nm.Parse("$GPWPL,5222.5111,N,00502.8649,E,WPT1")
+	nm.Parse("$GPRMC,163354.17,A,5222.5109,N,00502.8805,E,4.5,271.1,130319,,,D,V*24")
+	nm.Parse("$GPRMC,163355.67,A,5222.5110,N,00502.8773,E,4.5,272.3,130319,,,D,V*25")
+	nm.Parse("$GPRMC,163355.67,V,5222.5110,N,00502.8773,E,4.5,272.3,130319,,,D,V")
+	nm.Parse("$GPRMC,163400.19,A,5222.5111,N,00502.8679,E,4.4,272.6,130319,,,D,V*25")
+	nm.Parse("$GPRMC,163400.19,A,5222.5111,N,00502.8679,E,4.4,272.6,130319,,,D,V*25")
+	nm.Parse("$GPAPB,A,A,0.02617,R,N,V,V,210.0,T,WPT1,236.6,T,236.6,T,D*07")
+
+	var buf bytes.Buffer
+	if err := gpx.Write(&buf); err != nil {
+		t.Fatalf("write failed %s", err)
+	}
+	out := buf.String()
+	if strings.Count(out, "<trkseg>") != 2 {
+		t.Errorf("expected 2 track segments got %s", out)
+	}
+	if strings.Count(out, "<trkpt") != 3 {
+		t.Errorf("expected 3 track points after decimation got %s", out)
+	}
+	if !strings.Contains(out, `<trkpt lat="52.37518166666667" lon="5.048008333333334">`) ||
+		!strings.Contains(out, "<time>2019-03-13T16:33:54.17Z</time>") ||
+		!strings.Contains(out, "<nmea:sog>4.5</nmea:sog>") {
+		t.Errorf("track point incorrect got %s", out)
+	}
+	if !strings.Contains(out, "<name>WPT1</name>") || len(gpx.WaypointIDs()) != 1 {
+		t.Errorf("waypoint incorrect got %s ids %v", out, gpx.WaypointIDs())
+	}
+}
+
+func TestGPXRecorderSkipsBlankPositions(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	gpx := NewGPXRecorder(nm)
+	gpx.Attach()
+
+	nm.Parse("$GPRMC,163354.17,A,5222.5109,N,00502.8805,E,4.5,271.1,130319,,,D,V*24")
+	nm.Parse("$GPRMC,163355.67,V,,,,,,,130319,,,N,V")
+	nm.Update(map[string]string{"status": "A", "position": ""})
+	nm.Update(map[string]string{"position": "not a position"})
+	nm.Update(map[string]string{"wpl_position": "", "wpl_id": "BLANK"})
+
+	var buf bytes.Buffer
+	if err := gpx.Write(&buf); err != nil {
+		t.Fatalf("write failed %s", err)
+	}
+	out := buf.String()
+	if strings.Count(out, "<trkpt") != 1 || strings.Contains(out, `lat="0"`) || strings.Contains(out, "BLANK") {
+		t.Errorf("blank positions should be skipped got %s", out)
+	}
+}
+
+func TestGPXRecorderDerivedPositions(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.DeadReckoningPreferences(false, 0, true)
+	nm.AttachDeadReckoning()
+	gpx := NewGPXRecorder(nm)
+	gpx.Attach()
+	all := NewGPXRecorder(nm)
+	all.IncludeDerived(true)
+	all.Attach()
+
+	nm.Parse("$GPRMC,163354.17,A,5222.5109,N,00502.8805,E,4.5,271.1,130319,,,D,V*24")
+	nm.DeadReckon()
+
+	for rec, want := range map[*GPXRecorder]int{gpx: 1, all: 2} {
+		var buf bytes.Buffer
+		if err := rec.Write(&buf); err != nil {
+			t.Fatalf("write failed %s", err)
+		}
+		if got := strings.Count(buf.String(), "<trkpt"); got != want {
+			t.Errorf("expected %d track points got %d", want, got)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Grid reference systems which positions can be converted to
+type GridRef int
+
+const (
+	UTMGrid        GridRef = iota // 31U 448252 5411933
+	MGRSGrid                      // 31U DQ 48251 11932
+	MaidenheadGrid                // JN18du
+)
+
+// A Universal Transverse Mercator grid reference on the WGS84 ellipsoid.
+// Band is the latitude band letter C to X, N and above being in the northern hemisphere.
+// Easting and Northing are in metres.
+type UTM struct {
+	Zone     int
+	Band     byte
+	Easting  float64
+	Northing float64
+}
+
+const (
+	utmK0        = 0.9996
+	utmFalseE    = 500e3
+	utmFalseN    = 10000e3 // southern hemisphere
+	utmLatBands  = "CDEFGHJKLMNPQRSTUVWXX"
+	mgrsE100k    = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	mgrsN100kOdd = "ABCDEFGHJKLMNPQRSTUV"
+	mgrsN100kEvn = "FGHJKLMNPQRSTUVABCDE"
+)
+
+// Krüger series coefficients to sixth order in n for the WGS84 ellipsoid
+var utmA, utmE, utmAlpha, utmBeta = func() (float64, float64, [7]float64, [7]float64) {
+	n := wgs84F / (2 - wgs84F)
+	n2, n3, n4, n5, n6 := n*n, n*n*n, n*n*n*n, n*n*n*n*n, n*n*n*n*n*n
+	A := wgs84A / (1 + n) * (1 + n2/4 + n4/64 + n6/256)
+	e := math.Sqrt(wgs84F * (2 - wgs84F))
+	alpha := [7]float64{0,
+		1.0/2*n - 2.0/3*n2 + 5.0/16*n3 + 41.0/180*n4 - 127.0/288*n5 + 7891.0/37800*n6,
+		13.0/48*n2 - 3.0/5*n3 + 557.0/1440*n4 + 281.0/630*n5 - 1983433.0/1935360*n6,
+		61.0/240*n3 - 103.0/140*n4 + 15061.0/26880*n5 + 167603.0/181440*n6,
+		49561.0/161280*n4 - 179.0/168*n5 + 6601661.0/7257600*n6,
+		34729.0/80640*n5 - 3418889.0/1995840*n6,
+		212378941.0 / 319334400 * n6,
+	}
+	beta := [7]float64{0,
+		1.0/2*n - 2.0/3*n2 + 37.0/96*n3 - 1.0/360*n4 - 81.0/512*n5 + 96199.0/604800*n6,
+		1.0/48*n2 + 1.0/15*n3 - 437.0/1440*n4 + 46.0/105*n5 - 1118711.0/3870720*n6,
+		17.0/480*n3 - 37.0/840*n4 - 209.0/4480*n5 + 5569.0/90720*n6,
+		4397.0/161280*n4 - 11.0/504*n5 - 830251.0/7257600*n6,
+		4583.0/161280*n5 - 108847.0/3991680*n6,
+		20648693.0 / 638668800 * n6,
+	}
+	return A, e, alpha, beta
+}()
+
+// Converts lat and long in decimal degrees to a UTM grid reference, including the Norway
+// and Svalbard zone exceptions.  Returns an error outside the UTM limits of 80°S to 84°N
+func ToUTM(lat, long float64) (UTM, error) {
+	if lat < -80 || lat > 84 {
+		return UTM{}, fmt.Errorf("latitude %f is outside the UTM limits", lat)
+	}
+	long = wrap180(long)
+	zone := int(math.Floor((long+180)/6)) + 1
+	if zone > 60 {
+		zone = 60
+	}
+	band := utmLatBands[int(math.Floor(lat/8+10))]
+
+	if zone == 31 && band == 'V' && long >= 3 {
+		zone = 32 // Norway
+	}
+	if band == 'X' { // Svalbard
+		switch {
+		case zone == 32 && long < 9:
+			zone = 31
+		case zone == 32:
+			zone = 33
+		case zone == 34 && long < 21:
+			zone = 33
+		case zone == 34:
+			zone = 35
+		case zone == 36 && long < 33:
+			zone = 35
+		case zone == 36:
+			zone = 37
+		}
+	}
+
+	λ0 := toRad(float64((zone-1)*6 - 180 + 3))
+	φ := toRad(lat)
+	λ := toRad(long) - λ0
+
+	cosλ, sinλ := math.Cos(λ), math.Sin(λ)
+	τ := math.Tan(φ)
+	σ := math.Sinh(utmE * math.Atanh(utmE*τ/math.Sqrt(1+τ*τ)))
+	τʹ := τ*math.Sqrt(1+σ*σ) - σ*math.Sqrt(1+τ*τ)
+	ξʹ := math.Atan2(τʹ, cosλ)
+	ηʹ := math.Asinh(sinλ / math.Sqrt(τʹ*τʹ+cosλ*cosλ))
+
+	ξ, η := ξʹ, ηʹ
+	for j := 1; j <= 6; j++ {
+		ξ += utmAlpha[j] * math.Sin(2*float64(j)*ξʹ) * math.Cosh(2*float64(j)*ηʹ)
+		η += utmAlpha[j] * math.Cos(2*float64(j)*ξʹ) * math.Sinh(2*float64(j)*ηʹ)
+	}
+	x := utmK0*utmA*η + utmFalseE
+	y := utmK0 * utmA * ξ
+	if y < 0 {
+		y += utmFalseN
+	}
+	return UTM{Zone: zone, Band: band, Easting: x, Northing: y}, nil
+}
+
+// Returns the grid reference as zone and band followed by easting and northing in whole metres
+func (u UTM) String() string {
+	return fmt.Sprintf("%d%c %.0f %.0f", u.Zone, u.Band, math.Floor(u.Easting), math.Floor(u.Northing))
+}
+
+// Converts the grid reference to lat and long in decimal degrees
+func (u UTM) LatLong() (float64, float64, error) {
+	if u.Zone < 1 || u.Zone > 60 {
+		return 0, 0, fmt.Errorf("utm zone %d is not valid", u.Zone)
+	}
+	if !strings.ContainsRune(utmLatBands, rune(u.Band)) {
+		return 0, 0, fmt.Errorf("utm band %c is not valid", u.Band)
+	}
+	x := u.Easting - utmFalseE
+	y := u.Northing
+	if u.Band < 'N' {
+		y -= utmFalseN
+	}
+	η := x / (utmK0 * utmA)
+	ξ := y / (utmK0 * utmA)
+
+	ξʹ, ηʹ := ξ, η
+	for j := 1; j <= 6; j++ {
+		ξʹ -= utmBeta[j] * math.Sin(2*float64(j)*ξ) * math.Cosh(2*float64(j)*η)
+		ηʹ -= utmBeta[j] * math.Cos(2*float64(j)*ξ) * math.Sinh(2*float64(j)*η)
+	}
+	sinhηʹ := math.Sinh(ηʹ)
+	sinξʹ, cosξʹ := math.Sin(ξʹ), math.Cos(ξʹ)
+	τʹ := sinξʹ / math.Sqrt(sinhηʹ*sinhηʹ+cosξʹ*cosξʹ)
+
+	e2 := utmE * utmE
+	τi := τʹ
+	for i := 0; i < 20; i++ {
+		σi := math.Sinh(utmE * math.Atanh(utmE*τi/math.Sqrt(1+τi*τi)))
+		τiʹ := τi*math.Sqrt(1+σi*σi) - σi*math.Sqrt(1+τi*τi)
+		δτi := (τʹ - τiʹ) / math.Sqrt(1+τiʹ*τiʹ) * (1 + (1-e2)*τi*τi) / ((1 - e2) * math.Sqrt(1+τi*τi))
+		τi += δτi
+		if math.Abs(δτi) < 1e-12 {
+			break
+		}
+	}
+	λ0 := float64((u.Zone-1)*6 - 180 + 3)
+	return toDeg(math.Atan(τi)), wrap180(toDeg(math.Atan2(sinhηʹ, cosξʹ)) + λ0), nil
+}
+
+// Reads a UTM grid reference written as zone and band followed by easting and northing
+// eg "31U 448252 5411933"
+func ParseUTM(ref string) (UTM, error) {
+	parts := strings.Fields(ref)
+	if len(parts) != 3 || len(parts[0]) < 2 {
+		return UTM{}, fmt.Errorf("utm grid reference %q must be zone band easting northing", ref)
+	}
+	zoneBand := parts[0]
+	zone, err := strconv.Atoi(zoneBand[:len(zoneBand)-1])
+	if err != nil {
+		return UTM{}, fmt.Errorf("utm zone in %q is not valid", ref)
+	}
+	easting, errE := strconv.ParseFloat(parts[1], 64)
+	northing, errN := strconv.ParseFloat(parts[2], 64)
+	if errE != nil || errN != nil {
+		return UTM{}, fmt.Errorf("utm easting or northing in %q is not valid", ref)
+	}
+	return UTM{Zone: zone, Band: strings.ToUpper(zoneBand)[len(zoneBand)-1], Easting: easting, Northing: northing}, nil
+}
+
+// Converts lat and long in decimal degrees to an MGRS grid reference with digits (1 to 5) figures
+// each for easting and northing eg digits 5 gives 1 metre precision "31U DQ 48251 11932"
+func ToMGRS(lat, long float64, digits int) (string, error) {
+	if digits < 1 || digits > 5 {
+		return "", fmt.Errorf("mgrs digits must be 1 to 5")
+	}
+	u, err := ToUTM(lat, long)
+	if err != nil {
+		return "", err
+	}
+	col := int(math.Floor(u.Easting / 100e3))
+	row := int(math.Floor(u.Northing/100e3)) % 20
+	e100k := mgrsE100k[((u.Zone-1)%3)*8+col-1]
+	n100k := mgrsN100kOdd[row]
+	if (u.Zone-1)%2 == 1 {
+		n100k = mgrsN100kEvn[row]
+	}
+	scale := math.Pow(10, float64(5-digits))
+	easting := int(math.Floor(math.Mod(u.Easting, 100e3) / scale))
+	northing := int(math.Floor(math.Mod(u.Northing, 100e3) / scale))
+	return fmt.Sprintf("%d%c %c%c %0*d %0*d", u.Zone, u.Band, e100k, n100k, digits, easting, digits, northing), nil
+}
+
+// Converts an MGRS grid reference to lat and long in decimal degrees of the south west corner
+// of the grid square.  Spaces are optional eg "31UDQ4825111932"
+func MGRSToLatLong(ref string) (float64, float64, error) {
+	mgrs := strings.ToUpper(strings.Join(strings.Fields(ref), ""))
+	i := 0
+	for i < len(mgrs) && mgrs[i] >= '0' && mgrs[i] <= '9' {
+		i++
+	}
+	zone, err := strconv.Atoi(mgrs[:i])
+	if err != nil || zone < 1 || zone > 60 || len(mgrs) < i+3 {
+		return 0, 0, fmt.Errorf("mgrs grid reference %q is not valid", ref)
+	}
+	band := mgrs[i]
+	bandIndex := strings.IndexByte(utmLatBands, band)
+	e100kIndex := strings.IndexByte(mgrsE100k[((zone-1)%3)*8:((zone-1)%3)*8+8], mgrs[i+1])
+	n100kLetters := mgrsN100kOdd
+	if (zone-1)%2 == 1 {
+		n100kLetters = mgrsN100kEvn
+	}
+	n100kIndex := strings.IndexByte(n100kLetters, mgrs[i+2])
+	digits := mgrs[i+3:]
+	if bandIndex < 0 || e100kIndex < 0 || n100kIndex < 0 || len(digits)%2 != 0 || len(digits) > 10 {
+		return 0, 0, fmt.Errorf("mgrs grid reference %q is not valid", ref)
+	}
+	half := len(digits) / 2
+	scale := math.Pow(10, float64(5-half))
+	e, n := 0.0, 0.0
+	if half > 0 {
+		ei, errE := strconv.Atoi(digits[:half])
+		ni, errN := strconv.Atoi(digits[half:])
+		if errE != nil || errN != nil {
+			return 0, 0, fmt.Errorf("mgrs grid reference %q is not valid", ref)
+		}
+		e, n = float64(ei)*scale, float64(ni)*scale
+	}
+
+	// the 100 km letters repeat every 2000 km so find the block above the bottom of the band
+	bandLat := float64(bandIndex-10) * 8
+	bandBottom, _ := ToUTM(bandLat, float64((zone-1)*6-180+3))
+	nBand := math.Floor(bandBottom.Northing/100e3) * 100e3
+	n2M := 0.0
+	for n2M+float64(n100kIndex)*100e3+n < nBand {
+		n2M += 2000e3
+	}
+	u := UTM{Zone: zone, Band: band, Easting: float64(e100kIndex+1)*100e3 + e, Northing: n2M + float64(n100kIndex)*100e3 + n}
+	return u.LatLong()
+}
+
+// Converts lat and long in decimal degrees to a Maidenhead locator of length 2, 4, 6 or 8
+// characters eg 6 gives the sub square "JN18du"
+func ToMaidenhead(lat, long float64, length int) (string, error) {
+	if length < 2 || length > 8 || length%2 != 0 {
+		return "", fmt.Errorf("maidenhead locator length must be 2, 4, 6 or 8")
+	}
+	if lat < -90 || lat > 90 {
+		return "", fmt.Errorf("latitude %f is not valid", lat)
+	}
+	x := math.Min(wrap180(long)+180, 359.9999999)
+	y := math.Min(lat+90, 179.9999999)
+	loc := []byte{'A' + byte(x/20), 'A' + byte(y/10)}
+	x, y = math.Mod(x, 20), math.Mod(y, 10)
+	if length >= 4 {
+		loc = append(loc, '0'+byte(x/2), '0'+byte(y))
+		x, y = math.Mod(x, 2), math.Mod(y, 1)
+	}
+	if length >= 6 {
+		loc = append(loc, 'a'+byte(x*12), 'a'+byte(y*24))
+		x, y = math.Mod(x, 1.0/12), math.Mod(y, 1.0/24)
+	}
+	if length == 8 {
+		loc = append(loc, '0'+byte(x*120), '0'+byte(y*240))
+	}
+	return string(loc), nil
+}
+
+// Converts a Maidenhead locator to lat and long in decimal degrees of the centre of its square
+func MaidenheadToLatLong(loc string) (float64, float64, error) {
+	l := len(loc)
+	if l < 2 || l > 8 || l%2 != 0 {
+		return 0, 0, fmt.Errorf("maidenhead locator %q is not valid", loc)
+	}
+	loc = strings.ToUpper(loc)
+	sizes := [][2]float64{{20, 10}, {2, 1}, {1.0 / 12, 1.0 / 24}, {1.0 / 120, 1.0 / 240}}
+	bases := []byte{'A', '0', 'A', '0'}
+	limits := []byte{18, 10, 24, 10}
+	long, lat := -180.0, -90.0
+	for i := 0; i < l/2; i++ {
+		cx, cy := loc[2*i]-bases[i], loc[2*i+1]-bases[i]
+		if loc[2*i] < bases[i] || loc[2*i+1] < bases[i] || cx >= limits[i] || cy >= limits[i] {
+			return 0, 0, fmt.Errorf("maidenhead locator %q is not valid", loc)
+		}
+		long += float64(cx) * sizes[i][0]
+		lat += float64(cy) * sizes[i][1]
+	}
+	size := sizes[l/2-1]
+	return lat + size[1]/2, long + size[0]/2, nil
+}
+
+// Returns a grid reference for lat and long in decimal degrees. precision is the number of
+// digits for each of easting and northing in MGRS or the length of a Maidenhead locator
+// and is not used for UTM
+func GridReference(lat, long float64, grid GridRef, precision int) (string, error) {
+	switch grid {
+	case UTMGrid:
+		u, err := ToUTM(lat, long)
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	case MGRSGrid:
+		return ToMGRS(lat, long, precision)
+	case MaidenheadGrid:
+		return ToMaidenhead(lat, long, precision)
+	}
+	return "", fmt.Errorf("unknown grid reference %d", grid)
+}
+
+// Converts a grid reference to lat and long in decimal degrees
+func GridReferenceToLatLong(ref string, grid GridRef) (float64, float64, error) {
+	switch grid {
+	case UTMGrid:
+		u, err := ParseUTM(ref)
+		if err != nil {
+			return 0, 0, err
+		}
+		return u.LatLong()
+	case MGRSGrid:
+		return MGRSToLatLong(ref)
+	case MaidenheadGrid:
+		return MaidenheadToLatLong(ref)
+	}
+	return 0, 0, fmt.Errorf("unknown grid reference %d", grid)
+}
+
+// As GridReference but converts the position held in a variable, see Handle.Distance for the key
+func (h *Handle) GridReference(key string, grid GridRef, precision int) (string, error) {
+	lat, long, err := h.positionVar(key)
+	if err != nil {
+		return "", err
+	}
+	return GridReference(lat, long, grid, precision)
+}
+
+// Defines a variable holding the grid reference of a position variable, or of lat and long
+// variables separated by a comma, which is kept up to date each time either is updated eg
+//
+//	handle.AddGridVariable("position_mgrs", "position", MGRSGrid, 5)
+//	handle.AddGridVariable("locator", "my_lat,my_long", MaidenheadGrid, 6)
+func (h *Handle) AddGridVariable(name string, source string, grid GridRef, precision int) error {
+	if _, err := GridReference(0, 0, grid, precision); err != nil {
+		return err
+	}
+	set := func() {
+		if ref, err := h.GridReference(source, grid, precision); err == nil {
+			h.setVars(map[string]string{name: ref})
+		}
+	}
+	set()
+	keys := strings.Split(source, ",")
+	h.OnUpdate(func(results map[string]string) {
+		for _, key := range keys {
+			if _, ok := results[key]; ok {
+				set()
+				return
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,124 @@
+package nmea0183
+
+import (
+	"math"
+	"testing"
+)
+
+// Reference values checked against the Snyder transverse Mercator series and the
+// ARRL locator of W1AW
+func TestUTM(t *testing.T) {
+	lat, long := dms(48, 51, 29.5), dms(2, 17, 40.2) // Eiffel Tower
+	u, err := ToUTM(lat, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Zone != 31 || u.Band != 'U' {
+		t.Errorf("expected zone 31U got %d%c", u.Zone, u.Band)
+	}
+	expectNear(t, "easting", u.Easting, 448251.789, 0.001)
+	expectNear(t, "northing", u.Northing, 5411932.060, 0.001)
+	if u.String() != "31U 448251 5411932" {
+		t.Errorf("utm string got %s", u.String())
+	}
+
+	latr, longr, err := u.LatLong()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNear(t, "utm lat", latr, lat, 1e-9)
+	expectNear(t, "utm long", longr, long, 1e-9)
+
+	// southern hemisphere and zone exceptions
+	u, _ = ToUTM(-33.8568, 151.2153)
+	if u.Zone != 56 || u.Band != 'H' {
+		t.Errorf("expected zone 56H got %d%c", u.Zone, u.Band)
+	}
+	latr, longr, _ = u.LatLong()
+	expectNear(t, "south lat", latr, -33.8568, 1e-9)
+	expectNear(t, "south long", longr, 151.2153, 1e-9)
+	if u, _ := ToUTM(60, 5); u.Zone != 32 {
+		t.Errorf("Norway exception expected zone 32 got %d", u.Zone)
+	}
+	if u, _ := ToUTM(78, 10); u.Zone != 33 {
+		t.Errorf("Svalbard exception expected zone 33 got %d", u.Zone)
+	}
+	if _, err := ToUTM(85, 0); err == nil {
+		t.Error("expected error beyond UTM limits")
+	}
+
+	u, _ = ParseUTM("31U 448252 5411933")
+	latr, longr, _ = u.LatLong()
+	expectNear(t, "parsed lat", latr, lat, 1e-5)
+	expectNear(t, "parsed long", longr, long, 1e-5)
+}
+
+func TestMGRS(t *testing.T) {
+	lat, long := dms(48, 51, 29.5), dms(2, 17, 40.2)
+	mgrs, err := ToMGRS(lat, long, 5)
+	if err != nil || mgrs != "31U DQ 48251 11932" {
+		t.Errorf("mgrs got %s %v", mgrs, err)
+	}
+	if mgrs, _ := ToMGRS(lat, long, 3); mgrs != "31U DQ 482 119" {
+		t.Errorf("mgrs 3 digits got %s", mgrs)
+	}
+
+	latr, longr, err := MGRSToLatLong("31UDQ4825111932")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNear(t, "mgrs lat", latr, lat, 2e-5)
+	expectNear(t, "mgrs long", longr, long, 2e-5)
+
+	for _, p := range [][2]float64{{-33.8568, 151.2153}, {0.5, -0.5}, {-79.5, 170}, {83.5, -30}, {51.5, -179.9}} {
+		mgrs, _ := ToMGRS(p[0], p[1], 5)
+		latr, longr, err := MGRSToLatLong(mgrs)
+		if err != nil {
+			t.Errorf("%s %s", mgrs, err)
+		}
+		expectNear(t, mgrs+" lat", latr, p[0], 2e-5)
+		expectNear(t, mgrs+" long", longr, p[1], 2e-5/math.Cos(p[0]*math.Pi/180))
+	}
+}
+
+func TestMaidenhead(t *testing.T) {
+	loc, err := ToMaidenhead(41.714775, -72.727260, 6)
+	if err != nil || loc != "FN31pr" {
+		t.Errorf("W1AW expected FN31pr got %s %v", loc, err)
+	}
+	if loc, _ := ToMaidenhead(48.14666, 11.60833, 8); loc != "JN58td25" {
+		t.Errorf("Munich expected JN58td25 got %s", loc)
+	}
+	lat, long, err := MaidenheadToLatLong("FN31pr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNear(t, "maidenhead lat", lat, 41.7291667, 1e-6)
+	expectNear(t, "maidenhead long", long, -72.7083333, 1e-6)
+	if _, _, err := MaidenheadToLatLong("ZZ99"); err == nil {
+		t.Error("expected error for invalid locator")
+	}
+}
+
+func TestGridVariable(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AddGridVariable("position_mgrs", "position", MGRSGrid, 4)
+	nm.AddGridVariable("locator", "position", MaidenheadGrid, 6)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	if nm.Get("position_mgrs") != "30U XB 4730 2855" || nm.Get("locator") != "IO90ns" {
+		t.Errorf("grid variables not set got %s %s", nm.Get("position_mgrs"), nm.Get("locator"))
+	}
+}
+
+func TestGridVariableFromLatLong(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AddGridVariable("locator", "my_lat,my_long", MaidenheadGrid, 6)
+	nm.Update(map[string]string{"my_lat": "50° 47.3986'N", "my_long": "000° 54.6007'W"})
+	if nm.Get("locator") != "IO90ns" {
+		t.Errorf("grid variable from lat and long got %s", nm.Get("locator"))
+	}
+	nm.Update(map[string]string{"my_long": "001° 54.6007'W"})
+	if nm.Get("locator") != "IO90bs" {
+		t.Errorf("grid variable should follow long got %s", nm.Get("locator"))
+	}
+}
@@ -2,25 +2,60 @@ package nmea0183
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 )
 
 type settings struct {
-	realTime        bool
-	autoClearPeriod int64 // in milliseconds
+	realTime         bool
+	autoClearPeriod  int64  // in milliseconds
+	checkpointFile   string // snapshot file written periodically by Update, blank to disable
+	checkpointPeriod int64  // in milliseconds
+	posFormat        PositionFormat
+	posDecimals      int    // decimal places of positions given by Get, < 0 as received
+	normaliseDatum   bool   // convert positions received in a local datum to WGS84
+	outputDatum      string // datum code positions are written in, blank for WGS84
+	invalidAction    InvalidAction
+	blankPolicy      BlankPolicy // for variables without their own policy
+	fillMagVar       bool        // write model_var in place of a missing or blank mag_var
 }
 
 // The Handle structure contains private data used to define sentences, configuarations, and parsed data.
 // Methods on the struct allow parsing, updating of data and writing of sentences
 type Handle struct {
-	data        map[string]string
-	history     map[string]int64
-	messageDate time.Time
-	upDated     time.Time
-	settings    settings
-	sentences   *Sentences
+	data          map[string]string
+	history       map[string]int64
+	messageDate   time.Time
+	upDated       time.Time
+	settings      settings
+	sentences     *Sentences
+	checkpoint    checkpointState
+	encoder       *Encoder
+	onUpdate      []func(results map[string]string)
+	route         *Route
+	nav           navSettings
+	wind          windState
+	current       currentState
+	dr            drState
+	filter        filterState
+	trackers      map[string]*statTracker
+	circular      map[string]bool
+	valueLogs     map[string]*valueLog
+	keepAll       *HistoryLimits // history limits of variables not given to KeepHistory, nil to keep none
+	epoch         epochState
+	parsing       *Source // source of the sentence being parsed
+	sources       map[string]Source
+	sourceData    map[string]map[string]string
+	arbiters      map[string]*arbiter
+	invalid       map[string]bool // variables last set from a sentence flagged invalid
+	blankPolicies map[string]BlankPolicy
+	late          lateState
+	resultsDate   time.Time // date and time given by the results being merged, if resultsDated
+	resultsDated  bool
+	derive        derivedState
+
+	deviationCard    *DeviationCard
+	variationTimeout int64 // milliseconds hdg_var and mag_var are used for before model_var, 0 for always
 }
 
 // Returns a copy of the current data set or results of merged parsed sentences
@@ -29,14 +64,70 @@ func (h *Handle) GetMap() map[string]string {
 }
 
 // Returns a copy of a parsed variable in string format or null if not present
+// lat, long and position variables are given in the format set by SetPositionFormat
 func (h *Handle) Get(key string) string {
 	if val, ok := h.data[key]; ok {
-		return val
+		return h.formatPosition(key, val)
 	} else {
 		return ""
 	}
 }
 
+// Sets the format and number of decimal places of the last part used by Get for lat, long and
+// position variables and the precision used by LatLongToString when setting variables.
+// The default is DegreesDecimalMinutes with decimals < 0 meaning as received in the sentence
+// (or 4 places for LatLongToString).  Data is always held as degrees and decimal minutes
+// so that sentences can be written, LatLongToString uses as many places of minutes as needed
+// to keep the precision of decimals places in format.
+func (h *Handle) SetPositionFormat(format PositionFormat, decimals int) {
+	h.settings.posFormat = format
+	h.settings.posDecimals = decimals
+}
+
+// Returns the format of a variable, for variables parsed with ParsePrefixVar the format of the
+// variable without its prefix
+func (h *Handle) variableFormat(key string) string {
+	if format, ok := h.sentences.variables[key]; ok {
+		return format
+	}
+	return h.sentences.variables[strings.TrimPrefix(key, h.sources[key].prefix)]
+}
+
+func (h *Handle) formatPosition(key, val string) string {
+	if len(val) == 0 || (h.settings.posFormat == DegreesDecimalMinutes && h.settings.posDecimals < 0) {
+		return val
+	}
+	decimals := h.settings.posDecimals
+	if decimals < 0 {
+		decimals = map[PositionFormat]int{DecimalDegrees: 6, DegreesMinutesSeconds: 2}[h.settings.posFormat]
+	}
+	tType, _ := getConversion(h.sentences.variables[key])
+	switch tType {
+	case "lat":
+		lat, err := coordToFloat(val, 'N', 'S')
+		if err != nil {
+			return val
+		}
+		latStr, _, _ := LatLongToStringFormat(lat, 0, h.settings.posFormat, decimals)
+		return latStr
+	case "long":
+		long, err := coordToFloat(val, 'E', 'W')
+		if err != nil {
+			return val
+		}
+		_, longStr, _ := LatLongToStringFormat(0, long, h.settings.posFormat, decimals)
+		return longStr
+	case "position":
+		lat, long, err := LatLongToFloat(val)
+		if err != nil {
+			return val
+		}
+		latStr, longStr, _ := LatLongToStringFormat(lat, long, h.settings.posFormat, decimals)
+		return latStr + ", " + longStr
+	}
+	return val
+}
+
 // Returns a map of each data variable and the date and time it was updated
 func (h *Handle) DateMap() map[string]time.Time {
 	dateMap := make(map[string]time.Time)
@@ -55,16 +146,18 @@ func (h *Handle) Date(key string) time.Time {
 	}
 }
 
-// Deletes variables in data which have a millisecond time stamp less than timeMS
-func (h *Handle) DeleteBefore(timeMS int64) {
-	var timeNow int64
-
+// Returns the current time in milliseconds taken from the processor clock in real time
+// mode or from the last message date when processing historic data
+func (h *Handle) timeNow() int64 {
 	if h.settings.realTime {
-		timeNow = time.Now().UTC().UnixMilli()
-	} else {
-		timeNow = h.messageDate.UnixMilli()
+		return time.Now().UTC().UnixMilli()
 	}
-	timeBefore := timeNow - timeMS
+	return h.messageDate.UnixMilli()
+}
+
+// Deletes variables in data which have a millisecond time stamp less than timeMS
+func (h *Handle) DeleteBefore(timeMS int64) {
+	timeBefore := h.timeNow() - timeMS
 
 	for i, v := range h.history {
 		if v < timeBefore {
@@ -81,28 +174,94 @@ func (h *Handle) DeleteBefore(timeMS int64) {
 // Caution: do not write directly to the results map unless you understand the string format
 // associated with the variable in the sentence definitions
 func (h *Handle) Update(results map[string]string) {
+	h.updateReceived(results, time.Now().UTC())
+}
+
+// As Update for results received at the given time, the processor clock for Update and the
+// received time of a record for UpdateRecord
+func (h *Handle) updateReceived(results map[string]string, received time.Time) {
 
 	var timeStamp int64
 
 	if h.settings.autoClearPeriod > 0 {
 		h.DeleteBefore(h.settings.autoClearPeriod)
 	}
-	h.upDated = time.Now().UTC()
+	h.upDated = received
 
+	messageDate, dated := h.messageDateFrom(results)
 	if h.settings.realTime {
 		timeStamp = h.upDated.UnixMilli()
 	} else {
+		// a sentence giving its own date is time stamped with it
 		timeStamp = h.messageDate.UnixMilli()
+		if dated {
+			if results = h.rejectLate(results, messageDate); len(results) == 0 {
+				return
+			}
+			timeStamp = messageDate.UnixMilli()
+		}
+	}
+	h.update(h.recordDatum(results), timeStamp, messageDate, dated)
+}
+
+// Merges results into the data set marking each variable with timeStamp and, if dated,
+// sets the message date to the date and time the results give
+func (h *Handle) update(results map[string]string, timeStamp int64, messageDate time.Time, dated bool) {
+	results = h.applyBlankPolicy(results, timeStamp)
+	h.recordSourceData(results, timeStamp)
+	var from map[string]Source
+	if h.arbiters != nil && h.parsing != nil {
+		results, from = h.arbitrate(results, timeStamp)
 	}
+	h.merge(results, timeStamp, from)
+	if dated && h.advancesMessageDate(messageDate) {
+		h.messageDate = messageDate
+	}
+	h.resultsDate, h.resultsDated = messageDate, dated
+	h.notify(results, false)
+	h.resultsDate, h.resultsDated = time.Time{}, false
+	h.checkpointIfDue()
+}
 
+// merges results into the data set updated at timeStamp or, for values given by from, at the
+// time of their source
+func (h *Handle) merge(results map[string]string, timeStamp int64, from map[string]Source) {
+	if h.epoch.enabled {
+		h.epochUpdate(results, timeStamp)
+	}
+	h.recordProvenance(results, from, timeStamp)
+	for n, v := range results {
+		updated := timeStamp
+		if source, ok := from[n]; ok {
+			updated = source.Time.UnixMilli()
+		}
+		h.data[n] = v
+		h.history[n] = updated
+		if h.valueLogs != nil {
+			h.logValue(n, v, updated)
+		}
+		h.markValidity(n)
+	}
+}
+
+// Adds a function to be called each time the data set is updated with the results
+// merged in.  Used to keep derived data, exports and samplers up to date as sentences
+// are parsed.  Functions are called in the order added.  Values derived by the handle eg true
+// wind are given in a further call once all the functions have returned, see Derived.
+func (h *Handle) OnUpdate(f func(results map[string]string)) {
+	h.onUpdate = append(h.onUpdate, f)
+}
+
+// Returns the date and time given by a set of parsed results and true if a datetime
+// or a time and date is found, or a time alone once the date is known from an earlier
+// sentence or SetMessageDate.  Variables are recognised by their format type.
+func (h *Handle) messageDateFrom(results map[string]string) (time.Time, bool) {
 	vtypes := make(map[string]string)
 	for n, v := range results {
 		if template, found := h.sentences.variables[n]; found {
 			tType, _ := getConversion(template)
 			vtypes[tType] = v
 		}
-		h.data[n] = v
-		h.history[n] = timeStamp
 	}
 	rcDate := ""
 	if v, found := vtypes["datetime"]; found {
@@ -116,32 +275,55 @@ func (h *Handle) Update(results map[string]string) {
 			month, okm := vtypes["month"]
 			year, oky := vtypes["year"]
 			if okd && okm && oky {
-				d, _ := strconv.ParseInt(day, 10, 32)
-				m, _ := strconv.ParseInt(month, 10, 32)
-				y, _ := strconv.ParseInt(year, 10, 32)
-				if y < 60 {
-					y += 2000
-				} else {
-					y += 1900
-				}
-				date = fmt.Sprintf("%d-%02d-%02d", y, m, d)
+				date, _ = DateStrFromStrs(day, month, year)
 			}
 		}
-		if len(date) > 0 {
+		if len(date) > 0 && len(t) > 0 {
 			dateTime := date + "T" + t
-			zone := "00:00"
-			if z, ok := vtypes["zone"]; ok {
+			zone := "+00:00"
+			if z, ok := vtypes["zone"]; ok && len(z) > 0 {
 				zone = z
 			}
 			rcDate = dateTime + zone
+		} else if len(t) > 0 && h.messageDate.Year() > 0 {
+			return h.carryDate(t)
 		}
 	}
 	if len(rcDate) > 0 {
 		messageDate, err := time.Parse(time.RFC3339, rcDate)
 		if err == nil {
-			h.messageDate = messageDate
+			return messageDate, true
 		}
 	}
+	return time.Time{}, false
+}
+
+// Returns a UTC time of day on the date of the message date, or the day before or after if
+// the time has passed midnight, and true if the time is valid.  The day nearest the message date
+// is taken so after a gap of more than 12 hours without a dated sentence the date may be wrong.
+func (h *Handle) carryDate(timeOfDay string) (time.Time, bool) {
+	clock, err := time.Parse("15:04:05", timeOfDay)
+	if err != nil {
+		return time.Time{}, false
+	}
+	last := h.messageDate.UTC()
+	messageDate := time.Date(last.Year(), last.Month(), last.Day(),
+		clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), time.UTC)
+	if gap := messageDate.Sub(last); gap < -12*time.Hour {
+		messageDate = messageDate.AddDate(0, 0, 1)
+	} else if gap > 12*time.Hour {
+		messageDate = messageDate.AddDate(0, 0, -1)
+	}
+	return messageDate, true
+}
+
+// Sets the date used when processing historic data until a sentence gives one eg the date of
+// a log of sentences such as GGA which only give the time of day.  The time of day is taken from
+// the first sentence giving one and until then is noon UTC.  Each time of day is dated on the day
+// nearest the last message date so a gap of more than 12 hours between undated sentences, such
+// as a log stopped overnight, needs SetMessageDate called again for the new day.
+func (h *Handle) SetMessageDate(year int, month time.Month, day int) {
+	h.messageDate = time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
 }
 
 // Set handle settings preferences:
@@ -168,11 +350,7 @@ func (h *Handle) Preferences(clear int64, realTime bool) {
 // preFix, sentenceType, err = Parse(nmea_sentence)
 
 func (h *Handle) Parse(nmea string) (string, string, error) {
-	data, preFix, postFix, error := h.ParseToMap(nmea)
-	if error == nil {
-		h.Update(data)
-	}
-	return preFix, postFix, error
+	return h.parseFrom("", nmea, "")
 }
 
 // As Parse but writes to the data variables which have supplied your own prefix
@@ -182,12 +360,9 @@ func (h *Handle) Parse(nmea string) (string, string, error) {
 // results data map,  preFix, sentenceType, err = ParseToMap(nmea_sentence, variable_prefix)
 // or if the sentence prefix can be used to distinguish:
 // results data map,  preFix, sentenceType, err = ParseToMap(nmea_sentence, nmea_sentence[1:2])
+// The prefix is also recorded as the source name, see ParseFrom for sources without prefixes.
 func (h *Handle) ParsePrefixVar(nmea string, preFixVar string) (string, string, error) {
-	data, preFix, postFix, error := h.ParseToMap(nmea, preFixVar)
-	if error == nil {
-		h.Update(data)
-	}
-	return preFix, postFix, error
+	return h.parseFrom(preFixVar, nmea, preFixVar)
 }
 
 // Similar to Parse and ParsePrefixVar but does not update the data set but returns a map of the
@@ -214,12 +389,15 @@ func (h *Handle) ParseToMap(params ...string) (map[string]string, string, string
 	}
 	end_byte := len(nmea)
 	var err error
+	checkStatus := ChecksumNone
 	if nmea[end_byte-3] == '*' {
 		check_code := checksum(nmea[:end_byte-3])
 		end_byte -= 2
+		checkStatus = ChecksumOK
 		if check_code != nmea[end_byte:] {
 			err_mess := fmt.Sprintf("error: %s != %s", check_code, nmea[end_byte:])
 			err = fmt.Errorf("check sum error: %s", err_mess)
+			checkStatus = ChecksumError
 		}
 		end_byte--
 	}
@@ -249,6 +427,16 @@ func (h *Handle) ParseToMap(params ...string) (map[string]string, string, string
 			}
 		}
 	}
+	// variation given by HDG also sets mag_var as it did before HDG was parsed into hdg_var so
+	// that RMC can be written from it, SourceOf tells the two apart
+	if hdgVar := results[var_prefix+"hdg_var"]; len(hdgVar) > 0 {
+		if _, found := results[var_prefix+"mag_var"]; !found {
+			results[var_prefix+"mag_var"] = hdgVar
+		}
+	}
+	if h.encoder != nil {
+		h.encoder.encodeSentence(h, nmea, preFix, sentenceType, checkStatus, var_prefix, results)
+	}
 	return results, preFix, sentenceType, err
 }
 
@@ -273,20 +461,20 @@ func (h *Handle) LatLongToFloat(params ...string) (float64, float64, error) {
 // data which will be set by converting the lat and long float parameters
 // One variable name assumes a position string containing lat, long will be set
 // two variable names assumes that 2 variables one for lat and on for long will bes set
+// Minutes are given to 4 decimal places or as many as the precision set by SetPositionFormat
+// needs eg 5 for DecimalDegrees with 6 places
 // Returns an error.  Assumes Minus values are for South and West
 func (h *Handle) LatLongToString(latFloat, longFloat float64, params ...string) error {
 	if len(params) < 1 || len(params) > 2 {
 		return fmt.Errorf("illegal number of parmeters given to latlongtostring")
 	}
 
-	latStr, longStr, _ := LatLongToString(latFloat, longFloat)
-	var timeNow int64
-
-	if h.settings.realTime {
-		timeNow = time.Now().UTC().UnixMilli()
-	} else {
-		timeNow = h.messageDate.UnixMilli()
+	decimals := 4
+	if h.settings.posDecimals >= 0 {
+		decimals = minuteDecimalsFor(h.settings.posFormat, h.settings.posDecimals)
 	}
+	latStr, longStr, _ := LatLongToStringFormat(latFloat, longFloat, DegreesDecimalMinutes, decimals)
+	timeNow := h.timeNow()
 
 	if len(params) == 1 {
 		h.data[params[0]] = latStr + ", " + longStr
@@ -305,6 +493,9 @@ func (h *Handle) LatLongToString(latFloat, longFloat float64, params ...string)
 // The sentence prefix is parsed in the first parameter followed by a string which
 // matches the sentence definitions. The prefix is added in the resulting string after the $
 // and is included in the checksum. The prefix can be blank.
+// Each variable is written by its ValueState: present values are written, empty ones as blank
+// fields as they were received and absent or cleared ones as blank fields reported as missing
+// in the error.  Values given to WriteSentenceWith and prefixed variables follow the same rule.
 func (h *Handle) WriteSentence(manCode string, sentenceName string) (string, error) {
 	return h.WriteSentencePrefixVar(manCode, sentenceName, "")
 }
@@ -313,21 +504,35 @@ func (h *Handle) WriteSentence(manCode string, sentenceName string) (string, err
 // see ParsePrefixVar for how to create them
 
 func (h *Handle) WriteSentencePrefixVar(manCode string, sentenceName string, prefixVar string) (string, error) {
+	return h.writeSentence(manCode, sentenceName, prefixVar, nil)
+}
+
+// As WriteSentence but values given are used in place of data variables of the same name.
+// This allows sentences to be made from calculated values without changing the data set
+// eg a WPL for each waypoint of a route.
+func (h *Handle) WriteSentenceWith(manCode string, sentenceName string, values map[string]string) (string, error) {
+	return h.writeSentence(manCode, sentenceName, "", values)
+}
+
+func (h *Handle) writeSentence(manCode string, sentenceName string, prefixVar string, values map[string]string) (string, error) {
 	sentenceType := strings.ToLower(sentenceName)
 	madeSentence := strings.ToUpper(manCode + sentenceName)
 	var err error = nil
 	missing_data := ""
 	missing_var_def := ""
 	if varList, found := h.sentences.formats[sentenceType]; found {
+		values = h.outputDatumValues(varList, prefixVar, values)
+		values = h.fillMagVarValues(varList, prefixVar, values)
 		for _, v := range varList {
 			if vFormat, foundVar := h.sentences.variables[v]; foundVar {
 				_, cv := getConversion(vFormat)
 				lookup_var := prefixVar + v
-				if value, ok := h.data[lookup_var]; !ok || len(v) == 0 || v == "n/a" || len(value) == 0 {
+				value, state := h.writeValue(lookup_var, v, values)
+				if state != Present || v == "n/a" {
 					for i := 0; i < cv.fCount; i++ {
 						madeSentence += ","
 					}
-					if !ok && v != "n/a" {
+					if (state == Absent || state == Cleared) && v != "n/a" {
 						missing_data = fmt.Sprintf("%s;%s", missing_data, lookup_var)
 					}
 				} else {
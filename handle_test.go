@@ -0,0 +1,85 @@
+package nmea0183
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageDateWithoutZone(t *testing.T) {
+	// RMC gives a time and date but no zone which is taken as UTC
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.Update(map[string]string{"fix_time": "11:09:10.59", "fix_date": "2020-09-15"})
+	if want := time.Date(2020, 9, 15, 11, 9, 10, 590e6, time.UTC); !nm.messageDate.Equal(want) {
+		t.Errorf("message date got %s want %s", nm.messageDate, want)
+	}
+	// a blank zone is also UTC
+	sentences := DefaultSentences()
+	sentences.AddVariable("local_zone", "tz_h,tz_m")
+	nm = sentences.MakeHandle()
+	nm.Preferences(0, false)
+	nm.Update(map[string]string{"fix_time": "11:09:11.00", "fix_date": "2020-09-15", "local_zone": ""})
+	if want := time.Date(2020, 9, 15, 11, 9, 11, 0, time.UTC); !nm.messageDate.Equal(want) {
+		t.Errorf("message date with blank zone got %s want %s", nm.messageDate, want)
+	}
+}
+
+func TestHistoricTimeStamp(t *testing.T) {
+	// in historic mode variables are stamped with the date of the sentence giving them not the
+	// message date before it
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Parse("$GPRMC,110920.00,A,5047.3986,N,00054.6007,W,0.10,0.19,150920,0.24,W,D,V*73")
+	if want := time.Date(2020, 9, 15, 11, 9, 20, 0, time.UTC); !nm.Date("sog").Equal(want) {
+		t.Errorf("sog stamped %s want %s", nm.Date("sog").UTC(), want)
+	}
+	// undated sentences take the message date
+	nm.Parse("$SDDPT,12.3,0.5*62")
+	if want := time.Date(2020, 9, 15, 11, 9, 20, 0, time.UTC); !nm.Date("dbt").Equal(want) {
+		t.Errorf("dbt stamped %s want %s", nm.Date("dbt").UTC(), want)
+	}
+}
+
+func TestCarryDateForward(t *testing.T) {
+	gll := func(hhmmss string) string {
+		s := "$GPGLL,5047.3986,N,00054.6007,W," + hhmmss + ",A,A"
+		return s + "*" + checksum(s)
+	}
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.Parse(gll("235958.00"))
+	if nm.Date("position").UTC().Year() != 0 {
+		t.Errorf("without a date the time cannot be used got %s", nm.Date("position"))
+	}
+
+	nm.SetMessageDate(2020, time.September, 15)
+	nm.Parse(gll("235958.00"))
+	if want := time.Date(2020, 9, 15, 23, 59, 58, 0, time.UTC); !nm.Date("position").Equal(want) {
+		t.Errorf("time on the set date got %s", nm.Date("position"))
+	}
+	// past midnight
+	nm.Parse(gll("000001.50"))
+	if want := time.Date(2020, 9, 16, 0, 0, 1, 500e6, time.UTC); !nm.Date("position").Equal(want) {
+		t.Errorf("time after midnight got %s", nm.Date("position"))
+	}
+	// a late sentence from before midnight stays on the day before
+	nm.Parse(gll("235959.00"))
+	if want := time.Date(2020, 9, 15, 23, 59, 59, 0, time.UTC); !nm.Date("position").Equal(want) {
+		t.Errorf("time before midnight got %s", nm.Date("position"))
+	}
+
+	// the date from RMC is carried forward and old data expires
+	nm = DefaultSentences().MakeHandle()
+	nm.Preferences(60, false)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Parse("$SDDPT,12.3,0.5*62")
+	nm.Parse(gll("111500.00"))
+	nm.Parse(gll("111501.00"))
+	if want := time.Date(2020, 9, 15, 11, 15, 1, 0, time.UTC); !nm.DateMap()["position"].Equal(want) {
+		t.Errorf("carried date got %s", nm.DateMap()["position"])
+	}
+	if _, ok := nm.GetMap()["dbt"]; ok {
+		t.Error("dbt should have expired")
+	}
+}
@@ -0,0 +1,239 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// A compass deviation card giving the deviation, E positive W negative, at compass (sensor)
+// headings.  Deviation between headings on the card is interpolated.
+type DeviationCard struct {
+	headings   []float64
+	deviations []float64
+}
+
+// Makes a deviation card from a map of compass heading to deviation in degrees, E positive
+func NewDeviationCard(card map[float64]float64) (*DeviationCard, error) {
+	if len(card) == 0 {
+		return nil, fmt.Errorf("deviation card has no entries")
+	}
+	c := &DeviationCard{}
+	for heading := range card {
+		if heading < 0 || heading >= 360 {
+			return nil, fmt.Errorf("deviation card heading %f must be 0 to less than 360", heading)
+		}
+		c.headings = append(c.headings, heading)
+	}
+	sort.Float64s(c.headings)
+	for _, heading := range c.headings {
+		c.deviations = append(c.deviations, card[heading])
+	}
+	return c, nil
+}
+
+// Loads a deviation card from a file with a deviation map of compass heading to deviation eg
+//
+//	deviation:
+//	    0: 1.5
+//	    90: -2.0
+//
+// If no parameters uses defaults "." "deviation" "yaml",
+// 1st parameter is the path followed by the file name and format
+func LoadDeviationCard(setting ...string) (*DeviationCard, error) {
+	configSet := []string{".", "deviation", "yaml"}
+	copy(configSet, setting)
+
+	v := viper.New()
+	v.SetConfigName(configSet[1])
+	v.SetConfigType(configSet[2])
+	v.AddConfigPath(configSet[0])
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("deviation card could not be read: %w", err)
+	}
+	card := make(map[float64]float64)
+	for heading, deviation := range v.GetStringMapString("deviation") {
+		hdg, errH := strconv.ParseFloat(heading, 64)
+		dev, errD := strconv.ParseFloat(deviation, 64)
+		if errH != nil || errD != nil {
+			return nil, fmt.Errorf("deviation card entry %s: %s is not valid", heading, deviation)
+		}
+		card[hdg] = dev
+	}
+	return NewDeviationCard(card)
+}
+
+// Returns the deviation at a compass heading interpolating between the headings on the card
+func (c *DeviationCard) Deviation(compass float64) float64 {
+	compass = wrap360(compass)
+	l := len(c.headings)
+	i := sort.SearchFloat64s(c.headings, compass)
+	if i < l && c.headings[i] == compass {
+		return c.deviations[i]
+	}
+	// the card wraps round from the last heading to the first
+	before, after := (i+l-1)%l, i%l
+	span := wrap360(c.headings[after] - c.headings[before])
+	if span == 0 {
+		return c.deviations[before]
+	}
+	fraction := wrap360(compass-c.headings[before]) / span
+	return c.deviations[before] + fraction*(c.deviations[after]-c.deviations[before])
+}
+
+// Returns the compass heading which gives a magnetic heading, the inverse of adding Deviation
+func (c *DeviationCard) Compass(magnetic float64) float64 {
+	compass := magnetic
+	for i := 0; i < 20; i++ {
+		next := wrap360(magnetic - c.Deviation(compass))
+		if math.Abs(wrap180(next-compass)) < 1e-9 {
+			return next
+		}
+		compass = next
+	}
+	return compass
+}
+
+// Sets the deviation card used by the heading pipeline, nil to use the deviation given by HDG
+func (h *Handle) SetDeviationCard(card *DeviationCard) {
+	h.deviationCard = card
+}
+
+// Keeps sensor, magnetic and true headings in step as HDG, HDM and HDT are parsed, see Headings.
+// Headings derived by the handle, here or by AttachMagneticModel, are not passed back through the
+// pipeline as each would derive the other again.
+func (h *Handle) AttachHeadings() {
+	h.onParsedUpdate(func(results map[string]string) {
+		for _, key := range []string{"hdg_sensor", "hdm", "hdt"} {
+			if v, ok := results[key]; ok && len(v) > 0 {
+				h.Headings(key)
+				return
+			}
+		}
+	})
+}
+
+// Computes headings from the one given by key, hdg_sensor, hdm or hdt:
+//
+//	hdg_sensor        compass heading before deviation
+//	hdg_dev           deviation from the card if set otherwise as given by HDG or 0
+//	hdm               magnetic heading, hdg_sensor plus hdg_dev
+//	hdt               true heading, hdm plus variation
+//	variation         variation from HDG (hdg_var), RMC (mag_var) or the magnetic model (model_var),
+//	                  see VariationTimeout
+//	variation_source  HDG, RMC or model
+//
+// The true heading is only set when the variation is known.
+func (h *Handle) Headings(key string) error {
+	heading, err := h.Float(key)
+	if err != nil {
+		return err
+	}
+	values := map[string]string{}
+	variation, source, varErr := h.variation()
+	if varErr == nil {
+		values["variation"] = strconv.FormatFloat(variation, 'f', 1, 64)
+		values["variation_source"] = source
+	}
+
+	var magnetic float64
+	switch key {
+	case "hdg_sensor":
+		deviation := 0.0
+		if h.deviationCard != nil {
+			deviation = h.deviationCard.Deviation(heading)
+		} else if dev, err := h.Float("hdg_dev"); err == nil {
+			deviation = dev
+		}
+		values["hdg_dev"] = strconv.FormatFloat(deviation, 'f', 1, 64)
+		magnetic = wrap360(heading + deviation)
+		values["hdm"] = compassStr(magnetic, "M")
+	case "hdm":
+		magnetic = heading
+	case "hdt":
+		if varErr != nil {
+			return varErr
+		}
+		magnetic = wrap360(heading - variation)
+		values["hdm"] = compassStr(magnetic, "M")
+	default:
+		return fmt.Errorf("%s is not a heading", key)
+	}
+
+	if key != "hdg_sensor" {
+		compass := magnetic
+		if h.deviationCard != nil {
+			compass = h.deviationCard.Compass(magnetic)
+		}
+		values["hdg_sensor"] = strconv.FormatFloat(compass, 'f', 1, 64)
+		values["hdg_dev"] = strconv.FormatFloat(wrap180(magnetic-compass), 'f', 1, 64)
+	}
+	if key != "hdt" && varErr == nil {
+		values["hdt"] = compassStr(wrap360(magnetic+variation), "T")
+	}
+	h.setVars(values)
+	return nil
+}
+
+// Sets how long the variation given by HDG (hdg_var) or RMC (mag_var) is used after it was last
+// updated, older values are only used when the magnetic model gives none.  The default is 60
+// seconds and 0 uses them however old.
+func (h *Handle) VariationTimeout(timeout time.Duration) {
+	h.variationTimeout = timeout.Milliseconds()
+}
+
+// Returns the variation and its source from HDG, RMC or the magnetic model in that order, HDG and
+// RMC only while updated within the variation timeout.  Without a current value the latest is used.
+// mag_var parsed from HDG is not from RMC and is skipped, hdg_var gives it as HDG.
+func (h *Handle) variation() (float64, string, error) {
+	timeNow := h.timeNow()
+	found := false
+	var latest float64
+	var latestName string
+	var latestTime int64
+	for _, source := range []struct{ key, name string }{{"hdg_var", "HDG"}, {"mag_var", "RMC"}, {"model_var", "model"}} {
+		if source.key == "mag_var" {
+			if from, _ := h.SourceOf("mag_var"); from.Sentence == "hdg" {
+				continue
+			}
+		}
+		v, err := h.Float(source.key)
+		if err != nil {
+			continue
+		}
+		updated := h.history[source.key]
+		if source.key == "model_var" || h.variationTimeout <= 0 || timeNow-updated <= h.variationTimeout {
+			return v, source.name, nil
+		}
+		if !found || updated > latestTime {
+			found, latest, latestName, latestTime = true, v, source.name, updated
+		}
+	}
+	if found {
+		return latest, latestName, nil
+	}
+	return 0, "", fmt.Errorf("variation is not known")
+}
+
+// Returns HDG, HDM and HDT sentences for the current headings with HDG giving the variation
+// used, see Headings.  As with WriteNavigation the first error is returned.
+func (h *Handle) WriteHeadings(manCode string) ([]string, error) {
+	var sentences []string
+	var firstErr error
+	for _, name := range []string{"HDG", "HDM", "HDT"} {
+		var values map[string]string
+		if name == "HDG" {
+			values = map[string]string{"hdg_var": h.data["variation"]}
+		}
+		s, err := h.WriteSentenceWith(manCode, name, values)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+		sentences = append(sentences, s)
+	}
+	return sentences, firstErr
+}
@@ -0,0 +1,133 @@
+package nmea0183
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCard(t *testing.T) *DeviationCard {
+	card, err := NewDeviationCard(map[float64]float64{0: 2.0, 90: -1.0, 180: -3.0, 270: 1.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return card
+}
+
+func TestDeviationCard(t *testing.T) {
+	card := testCard(t)
+	expectNear(t, "deviation 90", card.Deviation(90), -1.0, 1e-9)
+	expectNear(t, "deviation 45", card.Deviation(45), 0.5, 1e-9)
+	expectNear(t, "deviation 315", card.Deviation(315), 1.5, 1e-9)
+	expectNear(t, "deviation 350", card.Deviation(350), 1+80.0/90, 1e-9)
+	compass := card.Compass(97.1)
+	expectNear(t, "compass", compass+card.Deviation(compass), 97.1, 1e-6)
+	compass = card.Compass(0.5)
+	expectNear(t, "compass near north", wrap180(compass+card.Deviation(compass)-0.5), 0, 1e-6)
+
+	dir := t.TempDir()
+	yaml := "deviation:\n    0: 2.0\n    90: -1.0\n    180: -3.0\n    270: 1.0\n"
+	if err := os.WriteFile(filepath.Join(dir, "deviation.yaml"), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDeviationCard(dir, "deviation", "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNear(t, "loaded deviation 315", loaded.Deviation(315), 1.5, 1e-9)
+	if _, err := NewDeviationCard(map[float64]float64{360: 1}); err == nil {
+		t.Error("expected error for heading 360")
+	}
+}
+
+func TestHeadingPipeline(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AttachHeadings()
+	nm.Parse("$HCHDG,98.3,0.5,E,12.6,W*52")
+	if nm.Get("hdm") != "98.8°M" || nm.Get("hdt") != "86.2°T" || nm.Get("variation_source") != "HDG" {
+		t.Errorf("hdg without card got %s %s %s", nm.Get("hdm"), nm.Get("hdt"), nm.Get("variation_source"))
+	}
+
+	nm.SetDeviationCard(testCard(t))
+	nm.Parse("$HCHDG,98.3,,,12.6,W*3C")
+	if nm.Get("hdg_dev") != "-1.2" || nm.Get("hdm") != "97.1°M" || nm.Get("hdt") != "84.5°T" {
+		t.Errorf("hdg with card got %s %s %s", nm.Get("hdg_dev"), nm.Get("hdm"), nm.Get("hdt"))
+	}
+	sentences, err := nm.WriteHeadings("HC")
+	if err != nil {
+		t.Error(err)
+	}
+	if sentences[0] != "$HCHDG,98.3,1.2,W,12.6,W*46" || sentences[1] != "$HCHDM,97.1,M*16" || sentences[2] != "$HCHDT,84.5,T*10" {
+		t.Errorf("headings written got %v", sentences)
+	}
+
+	// variation from RMC once HDG gives none
+	nm = DefaultSentences().MakeHandle()
+	nm.AttachHeadings()
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Parse("$HCHDM,172.5,M*28")
+	if nm.Get("hdt") != "172.3°T" || nm.Get("hdg_sensor") != "172.5" || nm.Get("variation_source") != "RMC" {
+		t.Errorf("hdm got %s %s %s", nm.Get("hdt"), nm.Get("hdg_sensor"), nm.Get("variation_source"))
+	}
+	nm.Parse("$HEHDT,90.0,T*16")
+	if nm.Get("hdm") != "90.2°M" {
+		t.Errorf("hdt got hdm %s", nm.Get("hdm"))
+	}
+}
+
+func TestHeadingsFromParsedValues(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AttachHeadings()
+	nm.Parse("$HCHDG,98.3,0.5,E,12.6,W*52")
+	nm.setVars(map[string]string{"hdt": "120.0°T"})
+	if nm.Get("hdm") != "98.8°M" || nm.Get("hdg_sensor") != "98.3" {
+		t.Errorf("a derived hdt should not pass through the pipeline got %s %s", nm.Get("hdm"), nm.Get("hdg_sensor"))
+	}
+}
+
+func TestHeadingVariationSources(t *testing.T) {
+	withChecksum := func(s string) string { return s + "*" + checksum(s) }
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AttachHeadings()
+
+	// HDG variation also sets mag_var so RMC can still be written from it
+	nm.Parse(withChecksum("$GPRMC,120000.00,A,5047.3986,N,00054.6007,W,5.0,90.0,150920,1.0,W,A,V"))
+	nm.Parse(withChecksum("$HCHDG,100.0,,,5.0,E"))
+	if nm.Get("mag_var") != "5.0" || nm.Get("variation_source") != "HDG" || nm.Get("hdt") != "105.0°T" {
+		t.Errorf("hdg variation got mag_var %s from %s hdt %s", nm.Get("mag_var"), nm.Get("variation_source"), nm.Get("hdt"))
+	}
+
+	if source, _ := nm.SourceOf("mag_var"); source.Sentence != "hdg" || source.Derived {
+		t.Errorf("mag_var from HDG should be parsed from hdg got %+v", source)
+	}
+	nm.ParsePrefixVar(withChecksum("$HCHDG,100.0,,,6.0,E"), "b_")
+	if nm.Get("b_mag_var") != "6.0" || nm.Get("b_hdg_var") != "6.0" {
+		t.Errorf("prefixed hdg variation got b_mag_var %s b_hdg_var %s", nm.Get("b_mag_var"), nm.Get("b_hdg_var"))
+	}
+
+	// a stale HDG variation gives way to a current RMC
+	nm.Parse(withChecksum("$GPRMC,120200.00,A,5047.3986,N,00054.6007,W,5.0,90.0,150920,1.0,W,A,V"))
+	nm.Parse(withChecksum("$HCHDM,100.0,M"))
+	if nm.Get("variation_source") != "RMC" || nm.Get("hdt") != "99.0°T" {
+		t.Errorf("stale hdg variation got %s hdt %s", nm.Get("variation_source"), nm.Get("hdt"))
+	}
+
+	// without a timeout HDG is used however old
+	nm.VariationTimeout(0)
+	nm.Parse(withChecksum("$HCHDM,100.0,M"))
+	if nm.Get("variation_source") != "HDG" || nm.Get("hdt") != "105.0°T" {
+		t.Errorf("hdg variation without timeout got %s hdt %s", nm.Get("variation_source"), nm.Get("hdt"))
+	}
+
+	// mag_var from HDG is not taken as RMC variation once HDG gives none
+	nm.VariationTimeout(time.Minute)
+	nm.Update(map[string]string{"datetime": "2020-09-15T12:10:00.00+00:00"})
+	nm.Parse(withChecksum("$HCHDG,100.0,,,5.0,E"))
+	nm.Parse(withChecksum("$HCHDG,100.0,,,,"))
+	nm.Parse(withChecksum("$HCHDM,100.0,M"))
+	if nm.Get("mag_var") != "5.0" || nm.Get("variation_source") == "RMC" {
+		t.Errorf("mag_var from HDG should not be used as RMC got %s from %s", nm.Get("mag_var"), nm.Get("variation_source"))
+	}
+}
@@ -0,0 +1,74 @@
+package nmea0183
+
+import (
+	"time"
+)
+
+// What is done with variables from a sentence older than the values already held
+type LateAction int
+
+const (
+	AcceptLate LateAction = iota // late values overwrite newer ones
+	RejectLate                   // late values are discarded
+	DivertLate                   // late values are discarded and given to the OnLate functions
+)
+
+type lateState struct {
+	action    LateAction
+	tolerance int64 // milliseconds a sentence may be older than the data and still be used
+	discarded int64 // messages with late values discarded
+	onLate    []func(results map[string]string, messageTime time.Time)
+}
+
+// Sets what is done, when processing historic data, with variables from a sentence whose message
+// time is more than tolerance older than the time the variable was last updated eg when merging
+// logs or from buffered inputs.  The default is AcceptLate.  Sentences without a date and time
+// are taken to be current.
+func (h *Handle) LatePreferences(action LateAction, tolerance time.Duration) {
+	h.late.action = action
+	h.late.tolerance = tolerance.Milliseconds()
+}
+
+// Adds a function called with the late values of a sentence when LatePreferences is DivertLate
+func (h *Handle) OnLate(f func(results map[string]string, messageTime time.Time)) {
+	h.late.onLate = append(h.late.onLate, f)
+}
+
+// Returns the number of messages which have had late values discarded
+func (h *Handle) DiscardedCount() int64 {
+	return h.late.discarded
+}
+
+// Returns results without variables older than those held, counting and diverting them as set
+// by LatePreferences
+func (h *Handle) rejectLate(results map[string]string, messageTime time.Time) map[string]string {
+	if h.late.action == AcceptLate || h.settings.realTime {
+		return results
+	}
+	ms := messageTime.UnixMilli()
+	current := make(map[string]string, len(results))
+	late := make(map[string]string)
+	for n, v := range results {
+		if last, ok := h.history[n]; ok && ms+h.late.tolerance < last {
+			late[n] = v
+		} else {
+			current[n] = v
+		}
+	}
+	if len(late) == 0 {
+		return results
+	}
+	h.late.discarded++
+	if h.late.action == DivertLate {
+		for _, f := range h.late.onLate {
+			f(late, messageTime)
+		}
+	}
+	return current
+}
+
+// Returns true if the message date should be set to messageTime, when late sentences are not
+// accepted it only moves forward
+func (h *Handle) advancesMessageDate(messageTime time.Time) bool {
+	return h.late.action == AcceptLate || h.settings.realTime || messageTime.After(h.messageDate)
+}
@@ -0,0 +1,47 @@
+package nmea0183
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLateRejection(t *testing.T) {
+	newer := "$GPRMC,110920.00,A,5047.3986,N,00054.6007,W,0.10,0.19,150920,0.24,W,D,V*73"
+	older := "$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75"
+	slightly := "$GPRMC,110919.50,A,5047.3986,N,00054.6007,W,0.09,0.19,150920,0.24,W,D,V*74"
+
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.Parse(newer)
+	nm.Parse(older)
+	if nm.Get("sog") != "0.08" {
+		t.Errorf("late values are accepted by default got %s", nm.Get("sog"))
+	}
+
+	nm = DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.LatePreferences(DivertLate, time.Second)
+	var diverted map[string]string
+	nm.OnLate(func(results map[string]string, messageTime time.Time) { diverted = results })
+	nm.Parse(newer)
+	nm.Parse(older)
+	if nm.Get("sog") != "0.10" || nm.Get("fix_time") != "11:09:20.00" || nm.DiscardedCount() != 1 {
+		t.Errorf("late sog got %s time %s discarded %d", nm.Get("sog"), nm.Get("fix_time"), nm.DiscardedCount())
+	}
+	if diverted["sog"] != "0.08" {
+		t.Errorf("diverted got %v", diverted)
+	}
+	if nm.messageDate.Second() != 20 {
+		t.Errorf("message date went back to %s", nm.messageDate)
+	}
+	// within the tolerance
+	nm.Parse(slightly)
+	if nm.Get("sog") != "0.09" || nm.DiscardedCount() != 1 {
+		t.Errorf("within tolerance got %s discarded %d", nm.Get("sog"), nm.DiscardedCount())
+	}
+	// sentences without a date are current
+	nm.Parse("$SDDPT,12.3,0.5*62")
+	if nm.Get("dbt") != "12.3" {
+		t.Errorf("undated sentence got %s", nm.Get("dbt"))
+	}
+}
@@ -0,0 +1,271 @@
+package nmea0183
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A World Magnetic Model read from a WMM.COF coefficient file as published by NOAA NCEI.
+// The coefficients are updated every 5 years, WMM2025 is included, see DefaultMagneticModel,
+// and later models are free to download from https://www.ncei.noaa.gov/products/world-magnetic-model
+type MagneticModel struct {
+	Name   string
+	Epoch  float64 // decimal year the coefficients apply to, valid for 5 years
+	maxN   int
+	g, h   [][]float64 // Schmidt semi-normalised Gauss coefficients nT
+	gd, hd [][]float64 // secular variation nT per year
+}
+
+const wmmRadius = 6371.2 // geomagnetic reference radius km
+
+// WMM2025 coefficients, public domain from NOAA NCEI
+//
+//go:embed WMM.COF
+var wmmCOF string
+
+// Returns the World Magnetic Model included in this package, WMM2025 valid from 2025.0 to 2030.0
+func DefaultMagneticModel() *MagneticModel {
+	model, _ := LoadWMM(strings.NewReader(wmmCOF))
+	return model
+}
+
+// Reads a model in the WMM.COF format: a header line of epoch, model name and date followed by
+// lines of n, m, g, h, g dot and h dot and ending with a line of 9s
+func LoadWMM(r io.Reader) (*MagneticModel, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("wmm coefficients have no header")
+	}
+	header := strings.Fields(scanner.Text())
+	if len(header) < 2 {
+		return nil, fmt.Errorf("wmm header %q is not valid", scanner.Text())
+	}
+	epoch, err := strconv.ParseFloat(header[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("wmm epoch %q is not valid", header[0])
+	}
+	type coef struct {
+		n, m         int
+		g, h, gd, hd float64
+	}
+	var coefs []coef
+	maxN := 0
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "9999") {
+			break
+		}
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("wmm coefficient line %q is not valid", scanner.Text())
+		}
+		var c coef
+		var values [4]float64
+		n, errN := strconv.Atoi(fields[0])
+		m, errM := strconv.Atoi(fields[1])
+		if errN != nil || errM != nil || n < 1 || m < 0 || m > n {
+			return nil, fmt.Errorf("wmm coefficient line %q is not valid", scanner.Text())
+		}
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(fields[i+2], 64); err != nil {
+				return nil, fmt.Errorf("wmm coefficient line %q is not valid", scanner.Text())
+			}
+		}
+		c.n, c.m, c.g, c.h, c.gd, c.hd = n, m, values[0], values[1], values[2], values[3]
+		coefs = append(coefs, c)
+		maxN = max(maxN, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("wmm coefficients could not be read: %w", err)
+	}
+	if len(coefs) == 0 {
+		return nil, fmt.Errorf("wmm coefficients not found")
+	}
+
+	model := &MagneticModel{Name: header[1], Epoch: epoch, maxN: maxN}
+	for _, table := range []*[][]float64{&model.g, &model.h, &model.gd, &model.hd} {
+		*table = make([][]float64, maxN+1)
+		for n := range *table {
+			(*table)[n] = make([]float64, n+1)
+		}
+	}
+	for _, c := range coefs {
+		model.g[c.n][c.m], model.h[c.n][c.m] = c.g, c.h
+		model.gd[c.n][c.m], model.hd[c.n][c.m] = c.gd, c.hd
+	}
+	return model, nil
+}
+
+// Reads a model from a WMM.COF file
+func LoadWMMFile(fileName string) (*MagneticModel, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("wmm file could not be opened: %w", err)
+	}
+	defer file.Close()
+	return LoadWMM(file)
+}
+
+// Returns the magnetic variation (declination) in degrees, E positive W negative, at lat and long
+// in decimal degrees, height above the WGS84 ellipsoid in km and date.
+// Returns an error if the date is outside the 5 years the model is valid for.
+func (m *MagneticModel) Declination(lat, long, height float64, date time.Time) (float64, error) {
+	year := decimalYear(date)
+	if year < m.Epoch || year > m.Epoch+5 {
+		return 0, fmt.Errorf("%s is valid from %.1f to %.1f not %.2f", m.Name, m.Epoch, m.Epoch+5, year)
+	}
+	dt := year - m.Epoch
+
+	// geodetic to geocentric spherical coordinates
+	φ := toRad(lat)
+	a := wgs84A / 1000
+	e2 := wgs84F * (2 - wgs84F)
+	rc := a / math.Sqrt(1-e2*math.Sin(φ)*math.Sin(φ))
+	p := (rc + height) * math.Cos(φ)
+	z := (rc*(1-e2) + height) * math.Sin(φ)
+	r := math.Hypot(p, z)
+	φc := math.Asin(z / r)
+	λ := toRad(long)
+
+	// Schmidt semi-normalised associated Legendre functions of colatitude and their derivatives
+	sinθ, cosθ := math.Cos(φc), math.Sin(φc)
+	n1 := m.maxN + 1
+	P := make([][]float64, n1)
+	dP := make([][]float64, n1)
+	S := make([][]float64, n1)
+	for n := 0; n < n1; n++ {
+		P[n], dP[n], S[n] = make([]float64, n+1), make([]float64, n+1), make([]float64, n+1)
+	}
+	P[0][0], S[0][0] = 1, 1
+	for n := 1; n < n1; n++ {
+		for k := 0; k <= n; k++ {
+			switch {
+			case k == n:
+				P[n][k] = sinθ * P[n-1][k-1]
+				dP[n][k] = sinθ*dP[n-1][k-1] + cosθ*P[n-1][k-1]
+			case n == 1 || k == n-1:
+				P[n][k] = cosθ * P[n-1][k]
+				dP[n][k] = cosθ*dP[n-1][k] - sinθ*P[n-1][k]
+			default:
+				K := float64((n-1)*(n-1)-k*k) / float64((2*n-1)*(2*n-3))
+				P[n][k] = cosθ*P[n-1][k] - K*P[n-2][k]
+				dP[n][k] = cosθ*dP[n-1][k] - sinθ*P[n-1][k] - K*dP[n-2][k]
+			}
+			if k == 0 {
+				S[n][0] = S[n-1][0] * float64(2*n-1) / float64(n)
+			} else {
+				d := 1.0
+				if k == 1 {
+					d = 2
+				}
+				S[n][k] = S[n][k-1] * math.Sqrt(float64(n-k+1)*d/float64(n+k))
+			}
+		}
+	}
+
+	var x, y, zf float64
+	for n := 1; n < n1; n++ {
+		ratio := math.Pow(wmmRadius/r, float64(n+2))
+		for k := 0; k <= n; k++ {
+			g := m.g[n][k] + dt*m.gd[n][k]
+			h := m.h[n][k] + dt*m.hd[n][k]
+			cosMλ, sinMλ := math.Cos(float64(k)*λ), math.Sin(float64(k)*λ)
+			pnm, dpnm := S[n][k]*P[n][k], S[n][k]*dP[n][k]
+			x += ratio * (g*cosMλ + h*sinMλ) * dpnm
+			if sinθ > 1e-10 {
+				y += ratio * float64(k) * (g*sinMλ - h*cosMλ) * pnm / sinθ
+			}
+			zf -= ratio * float64(n+1) * (g*cosMλ + h*sinMλ) * pnm
+		}
+	}
+	// rotate from geocentric to geodetic, y is unchanged
+	ψ := φc - φ
+	x = x*math.Cos(ψ) - zf*math.Sin(ψ)
+	return toDeg(math.Atan2(y, x)), nil
+}
+
+func decimalYear(t time.Time) float64 {
+	t = t.UTC()
+	start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	return float64(t.Year()) + float64(t.Sub(start))/float64(end.Sub(start))
+}
+
+// Uses the magnetic model to compute the variation at the current position and date each time
+// the position is updated and stores it in model_var.  If fillMagVar is true sentences are written
+// with model_var in place of a missing or blank mag_var or hdg_var eg so that RMC or HDG can be
+// written.  mag_var and hdg_var are left as received so they are never mistaken for a variation
+// given by RMC or HDG.
+// Headings are then kept in step: hdt is derived when hdm is received and hdm when hdt is received
+// using hdg_var or mag_var if known or model_var.
+// Only parsed values are used so a derived position eg from dead reckoning does not move the model
+// and hdt and hdm derived here or by AttachHeadings are not converted back.
+func (h *Handle) AttachMagneticModel(m *MagneticModel, fillMagVar bool) {
+	h.settings.fillMagVar = fillMagVar
+	h.onParsedUpdate(func(results map[string]string) {
+		if pos, ok := results["position"]; ok && len(pos) > 0 {
+			lat, long, err := LatLongToFloat(pos)
+			if err == nil {
+				if dec, err := m.Declination(lat, long, 0, time.UnixMilli(h.timeNow())); err == nil {
+					h.setVars(map[string]string{"model_var": strconv.FormatFloat(dec, 'f', 1, 64)})
+				}
+			}
+		}
+
+		variation, _, err := h.variation()
+		if err != nil {
+			return
+		}
+		_, hasHdm := results["hdm"]
+		_, hasHdt := results["hdt"]
+		if hdm, err := h.Float("hdm"); err == nil && hasHdm && !hasHdt {
+			h.setVars(map[string]string{"hdt": compassStr(wrap360(hdm+variation), "T")})
+		}
+		if hdt, err := h.Float("hdt"); err == nil && hasHdt && !hasHdm {
+			h.setVars(map[string]string{"hdm": compassStr(wrap360(hdt-variation), "M")})
+		}
+	})
+}
+
+// Returns values with model_var given for a missing or blank mag_var or hdg_var when writing a
+// sentence which has one eg RMC or HDG, see AttachMagneticModel
+func (h *Handle) fillMagVarValues(varList []string, prefixVar string, values map[string]string) map[string]string {
+	modelVar := h.data["model_var"]
+	if !h.settings.fillMagVar || len(modelVar) == 0 {
+		return values
+	}
+	var out map[string]string
+	for _, key := range []string{"mag_var", "hdg_var"} {
+		if !slices.Contains(varList, key) {
+			continue
+		}
+		variation, given := values[key]
+		if !given {
+			variation = h.data[prefixVar+key]
+		}
+		if len(variation) > 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(values)+1)
+			for k, v := range values {
+				out[k] = v
+			}
+		}
+		out[key] = modelVar
+	}
+	if out == nil {
+		return values
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package nmea0183
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A tilted dipole for which the declination at the equator is atan2(g11 sin λ - h11 cos λ, -g10)
+const testCOF = `    2020.0            TEST-DIPOLE     01/01/2020
+  1  0  -30000.0       0.0        0.0        0.0
+  1  1   -2000.0    5000.0        0.0      100.0
+999999999999999999999999999999999999999999999999
+`
+
+func TestMagneticModel(t *testing.T) {
+	m, err := LoadWMM(strings.NewReader(testCOF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "TEST-DIPOLE" || m.Epoch != 2020 {
+		t.Errorf("header got %s %f", m.Name, m.Epoch)
+	}
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	dec, _ := m.Declination(0, 0, 0, epoch)
+	expectNear(t, "declination", dec, -9.4623, 1e-4)
+	dec, _ = m.Declination(0, 90, 0, epoch)
+	expectNear(t, "declination 90E", dec, -3.8141, 1e-4)
+	dec, _ = m.Declination(0, 0, 0, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	expectNear(t, "declination with secular variation", dec, -9.8336, 1e-4)
+	if _, err := m.Declination(0, 0, 0, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected error outside model validity")
+	}
+
+	zonal, _ := LoadWMM(strings.NewReader("2020.0 ZONAL\n1 0 -30000.0 0.0 0.0 0.0\n2 0 -2500.0 0.0 0.0 0.0\n2 1 0.0 0.0 0.0 0.0\n"))
+	dec, _ = zonal.Declination(50.8, -0.9, 0, epoch)
+	expectNear(t, "zonal declination", dec, 0, 1e-9)
+
+	if _, err := LoadWMM(strings.NewReader("2020.0 BAD\n1 2 0 0 0 0\n")); err == nil {
+		t.Error("expected error for m > n")
+	}
+}
+
+func TestWMMTestValues(t *testing.T) {
+	// test values published by NOAA with WMM2020
+	m, err := LoadWMMFile("testdata/WMM2020.COF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []struct {
+		year         float64
+		height       float64
+		lat, long, d float64
+	}{
+		{2020.0, 0, 80, 0, -1.28},
+		{2020.0, 0, 0, 120, 0.16},
+		{2020.0, 0, -80, 240, 69.36},
+		{2020.0, 100, 80, 0, -1.70},
+		{2020.0, 100, 0, 120, 0.16},
+		{2020.0, 100, -80, 240, 68.78},
+		{2022.5, 0, 80, 0, 0.01},
+		{2022.5, 0, 0, 120, -0.06},
+		{2022.5, 0, -80, 240, 69.13},
+		{2022.5, 100, 80, 0, -0.41},
+		{2022.5, 100, 0, 120, -0.05},
+		{2022.5, 100, -80, 240, 68.55},
+	}
+	for _, v := range values {
+		date := time.Date(int(v.year), 1, 1, 0, 0, 0, 0, time.UTC)
+		if v.year > float64(int(v.year)) {
+			date = time.Date(int(v.year), 7, 2, 12, 0, 0, 0, time.UTC)
+		}
+		dec, err := m.Declination(v.lat, v.long, v.height, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectNear(t, fmt.Sprintf("declination %.1f %.0fkm %.0f %.0f", v.year, v.height, v.lat, v.long), dec, v.d, 0.005)
+	}
+
+	// the included WMM2025 continues from WMM2020 and gives about 1°E at Greenwich in 2025
+	wmm := DefaultMagneticModel()
+	if wmm == nil || wmm.Name != "WMM-2025" || wmm.Epoch != 2025 {
+		t.Fatalf("default model got %+v", wmm)
+	}
+	for _, pos := range [][2]float64{{51.48, 0}, {50.8, -1.3}, {-33.9, 151.2}, {40.7, -74.0}} {
+		old, _ := m.Declination(pos[0], pos[1], 0, time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC))
+		dec, _ := wmm.Declination(pos[0], pos[1], 0, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		expectNear(t, fmt.Sprintf("WMM2025 at %.1f %.1f", pos[0], pos[1]), dec, old, 0.3)
+	}
+	dec, _ := wmm.Declination(51.48, 0, 0, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	expectNear(t, "Greenwich", dec, 1.0, 0.1)
+	if _, err := wmm.Declination(0, 0, 0, time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected error after WMM2025 validity")
+	}
+}
+
+func TestAttachMagneticModel(t *testing.T) {
+	m, _ := LoadWMM(strings.NewReader(testCOF))
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AttachMagneticModel(m, true)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,,,D,V*3A")
+	lat, long, _ := nm.LatLongToFloat("position")
+	dec, _ := m.Declination(lat, long, 0, time.Date(2020, 9, 15, 11, 9, 10, 590e6, time.UTC))
+	modelVar, _ := nm.Float("model_var")
+	expectNear(t, "model_var", modelVar, dec, 0.05)
+	if nm.Get("mag_var") != "" {
+		t.Errorf("mag_var should be left as received got %s", nm.Get("mag_var"))
+	}
+	rmc, _ := nm.WriteSentence("GP", "RMC")
+	if !strings.Contains(rmc, ",150920,"+strings.TrimPrefix(nm.Get("model_var"), "-")+",W,D,") {
+		t.Errorf("rmc should include mag_var got %s", rmc)
+	}
+	if _, source, _ := nm.variation(); source != "model" {
+		t.Errorf("variation should be from the model got %s", source)
+	}
+
+	nm.Parse("$HCHDM,10.0,M*18")
+	hdt, _ := nm.Float("hdt")
+	expectNear(t, "hdt", hdt, wrap360(10+modelVar), 1e-9)
+
+	// the written variation follows the model as the position changes
+	sydney := "$GPRMC,120000.00,A,3400.0000,S,15100.0000,E,5.0,90.0,150920,,,D,V"
+	nm.Parse(sydney + "*" + checksum(sydney))
+	rmc, _ = nm.WriteSentence("GP", "RMC")
+	if model := nm.Get("model_var"); model == fmt.Sprintf("%.1f", modelVar) || !strings.Contains(rmc, ",150920,"+strings.TrimPrefix(model, "-")+",") {
+		t.Errorf("rmc should follow model_var %s got %s", model, rmc)
+	}
+}
+
+func TestMagneticModelWritesHDG(t *testing.T) {
+	m, _ := LoadWMM(strings.NewReader(testCOF))
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AttachMagneticModel(m, true)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,,,D,V*3A")
+	hdgIn := "$HCHDG,100.0,1.0,E,,"
+	nm.Parse(hdgIn + "*" + checksum(hdgIn))
+	hdg, err := nm.WriteSentence("HC", "HDG")
+	if model := strings.TrimPrefix(nm.Get("model_var"), "-"); err != nil || !strings.HasPrefix(hdg, "$HCHDG,100.0,1.0,E,"+model+",W*") {
+		t.Errorf("hdg should include the model variation %s got %s %v", model, hdg, err)
+	}
+	if nm.Get("hdg_var") != "" {
+		t.Errorf("hdg_var should be left as received got %s", nm.Get("hdg_var"))
+	}
+}
+
+func TestMagneticModelFromParsedValues(t *testing.T) {
+	m, _ := LoadWMM(strings.NewReader(testCOF))
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AttachMagneticModel(m, false)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,,,D,V*3A")
+	modelVar := nm.Get("model_var")
+	nm.setVars(map[string]string{"position": "10° 00.0000'S, 120° 00.0000'E"})
+	if nm.Get("model_var") != modelVar {
+		t.Errorf("a derived position should not update model_var got %s want %s", nm.Get("model_var"), modelVar)
+	}
+}
@@ -0,0 +1,184 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type navSettings struct {
+	arrivalRadius float64 // in nautical miles
+	rhumb         bool
+	autoAdvance   bool
+	fixTimeout    int64 // milliseconds after which the position is stale, 0 to not check
+}
+
+// Set navigation preferences used by Navigate:
+// arrivalRadius in nautical miles of the arrival circle around the destination waypoint
+// rhumb = true for rhumb line bearing and distance to the waypoint, false for great circle
+// autoAdvance = true to make the next leg of the route active on arrival or passing the waypoint
+func (h *Handle) NavigationPreferences(arrivalRadius float64, rhumb bool, autoAdvance bool) {
+	h.nav.arrivalRadius = arrivalRadius
+	h.nav.rhumb = rhumb
+	h.nav.autoAdvance = autoAdvance
+}
+
+// Sets the age of the position after which Navigate gives ap_status and ap_loran V, the default
+// is 10 seconds and 0 does not check the age
+func (h *Handle) NavigationFixTimeout(timeout time.Duration) {
+	h.nav.fixTimeout = timeout.Milliseconds()
+}
+
+// Makes a single leg route from the current position to the destination waypoint
+func (h *Handle) SetDestination(name string, lat, long float64) error {
+	posLat, posLong, err := h.positionFloat("position")
+	if err != nil {
+		return err
+	}
+	h.SetRoute(NewRoute("", []Waypoint{{Name: "", Lat: posLat, Long: posLong}, {Name: name, Lat: lat, Long: long}}))
+	return nil
+}
+
+// Navigates each time the position is updated, see Navigate.  Positions derived by the handle eg
+// by dead reckoning do not trigger it so the autopilot is steered from fixes only.
+func (h *Handle) AttachNavigation() {
+	h.onParsedUpdate(func(results map[string]string) {
+		if pos, ok := results["position"]; ok && len(pos) > 0 {
+			h.Navigate()
+		}
+	})
+}
+
+// Computes navigation to the destination of the active leg of the route from the current position
+// and stores the results in the variables used by APB, APA, RMB, BWC, XTE and AAM:
+//
+//	bearing_position_to_waypt, hts, btw    bearing to the destination
+//	bearing_to_waypt                       leg bearing from origin to destination
+//	dtw, bwc_range                         distance to the destination Nm
+//	bwc_mag                                magnetic bearing if variation is known
+//	xte, rmb_xte                           cross track error from the leg with direction to steer
+//	arrived_circle, passed_waypt           A once inside the arrival circle or passed the perpendicular
+//	vmg, eta                               velocity made good towards the destination and time of arrival
+//	                                       if sog and tmg are known
+//	ap_status                              A or V if the fix has status V, faa_mode N or is stale
+//	ap_loran                               A or V if the fix is stale, see NavigationFixTimeout
+//	ap_mode                                faa_mode if known otherwise A
+//
+// If auto advance is set the next leg is made active on arrival or passing the waypoint
+func (h *Handle) Navigate() error {
+	if h.route == nil {
+		return fmt.Errorf("no route or destination has been set")
+	}
+	lat, long, err := h.positionFloat("position")
+	if err != nil {
+		return err
+	}
+	origin, dest, ok := h.route.ActiveLeg()
+	if !ok {
+		return fmt.Errorf("route has no active leg")
+	}
+
+	var dist, bearing float64
+	if h.nav.rhumb {
+		dist = RhumbDistance(lat, long, dest.Lat, dest.Long)
+		bearing = RhumbBearing(lat, long, dest.Lat, dest.Long)
+	} else {
+		dist = Distance(lat, long, dest.Lat, dest.Long)
+		bearing = Bearing(lat, long, dest.Lat, dest.Long)
+	}
+	legBearing := Bearing(origin.Lat, origin.Long, dest.Lat, dest.Long)
+	legLength := Distance(origin.Lat, origin.Long, dest.Lat, dest.Long)
+	xte := CrossTrackDistance(lat, long, origin.Lat, origin.Long, dest.Lat, dest.Long)
+	along := AlongTrackDistance(lat, long, origin.Lat, origin.Long, dest.Lat, dest.Long)
+
+	steer := "R"
+	if xte > 0 {
+		steer = "L"
+	}
+	xteStr := steer + strconv.FormatFloat(math.Abs(xte), 'f', 3, 64) + "N"
+	arrived := "V"
+	if dist <= h.nav.arrivalRadius {
+		arrived = "A"
+	}
+	passed := "V"
+	if along >= legLength {
+		passed = "A"
+	}
+
+	apStatus, apLoran := "A", "A"
+	if h.nav.fixTimeout > 0 && h.timeNow()-h.history["position"] > h.nav.fixTimeout {
+		apStatus, apLoran = "V", "V"
+	}
+	if h.data["status"] == "V" || h.data["faa_mode"] == "N" {
+		apStatus = "V"
+	}
+
+	values := map[string]string{
+		"ap_status":                 apStatus,
+		"ap_loran":                  apLoran,
+		"bearing_position_to_waypt": compassStr(bearing, "T"),
+		"hts":                       compassStr(bearing, "T"),
+		"btw":                       strconv.FormatFloat(bearing, 'f', 1, 64),
+		"bearing_to_waypt":          compassStr(legBearing, "T"),
+		"bearing_origin_to_waypt":   compassStr(legBearing, "T"),
+		"dtw":                       strconv.FormatFloat(dist, 'f', 3, 64),
+		"bwc_range":                 strconv.FormatFloat(dist, 'f', 3, 64),
+		"xte":                       xteStr,
+		"rmb_xte":                   xteStr,
+		"arrived_circle":            arrived,
+		"passed_waypt":              passed,
+		"arrival_radius":            strconv.FormatFloat(h.nav.arrivalRadius, 'f', -1, 64),
+		"radius_units":              "N",
+	}
+	if mode, ok := h.data["faa_mode"]; ok && len(mode) > 0 {
+		values["ap_mode"] = mode
+	} else {
+		values["ap_mode"] = "A"
+	}
+	if magVar, _, err := h.variation(); err == nil {
+		values["bwc_mag"] = compassStr(bearing-magVar, "M")
+	}
+	sog, errSog := h.Float("sog")
+	tmg, errTmg := h.Float("tmg")
+	if errSog == nil && errTmg == nil {
+		vmg := sog * math.Cos((tmg-bearing)*math.Pi/180)
+		values["vmg"] = strconv.FormatFloat(vmg, 'f', 2, 64)
+		if vmg > 0.01 {
+			eta := time.UnixMilli(h.timeNow()).Add(time.Duration(dist / vmg * float64(time.Hour)))
+			values["eta"] = dateTimeStr(eta)
+		} else {
+			values["eta"] = ""
+		}
+	}
+	h.setVars(values)
+
+	if h.nav.autoAdvance && (arrived == "A" || passed == "A") && h.AdvanceLeg() {
+		return h.Navigate()
+	}
+	return nil
+}
+
+// Returns the APB, RMB, BWC, XTE and AAM sentences for the current navigation, see Navigate.
+// As with WriteSentence a best attempt is made at each sentence and the first error is returned
+// eg faa_mode is missing until an RMC has been parsed.
+func (h *Handle) WriteNavigation(manCode string) ([]string, error) {
+	var sentences []string
+	var firstErr error
+	for _, name := range []string{"APB", "RMB", "BWC", "XTE", "AAM"} {
+		s, err := h.WriteSentence(manCode, name)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+		sentences = append(sentences, s)
+	}
+	return sentences, firstErr
+}
+
+// Returns a position variable as floats or an error if it is not present or blank
+func (h *Handle) positionFloat(key string) (float64, float64, error) {
+	if pos, ok := h.data[key]; !ok || len(pos) == 0 {
+		return 0, 0, fmt.Errorf("no value for %s", key)
+	}
+	return h.LatLongToFloat(key)
+}
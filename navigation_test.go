@@ -0,0 +1,104 @@
+package nmea0183
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNavigate(t *testing.T) {
+	route, _ := ReadGPXRoute(strings.NewReader(testGPXRoute))
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.SetRoute(route)
+	nm.AttachNavigation()
+
+	nm.Parse("$GPRMC,120000.00,A,5045.6000,N,00112.0000,W,5.0,100.0,150920,1.0,W,A,V*46")
+
+	expected := map[string]string{
+		"dtw":                       "3.846",
+		"btw":                       "98.9",
+		"bearing_position_to_waypt": "98.9°T",
+		"bwc_mag":                   "99.9°M",
+		"bearing_origin_to_waypt":   "97.4°T",
+		"xte":                       "R0.096N",
+		"arrived_circle":            "V",
+		"passed_waypt":              "V",
+		"vmg":                       "5.00",
+		"eta":                       "2020-09-15T12:46:09.26+00:00",
+	}
+	for k, v := range expected {
+		if nm.Get(k) != v {
+			t.Errorf("%s expected %s got %s", k, v, nm.Get(k))
+		}
+	}
+
+	sentences, err := nm.WriteNavigation("GP")
+	if err != nil {
+		t.Errorf("navigation sentences error %s", err)
+	}
+	if sentences[1] != "$GPRMB,A,0.096,R,Cowes,NoMansLand,5045.0000,N,00106.0000,W,3.846,98.9,5.00,V,A*15" {
+		t.Errorf("rmb incorrect got %s", sentences[1])
+	}
+	if sentences[3] != "$GPXTE,A,A,0.096,R,N,A*22" {
+		t.Errorf("xte incorrect got %s", sentences[3])
+	}
+}
+
+func TestNavigateFromFixesOnly(t *testing.T) {
+	route, _ := ReadGPXRoute(strings.NewReader(testGPXRoute))
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.SetRoute(route)
+	nm.AttachNavigation()
+	nm.Parse("$GPRMC,120000.00,A,5045.6000,N,00112.0000,W,5.0,100.0,150920,1.0,W,A,V*46")
+	nm.setVars(map[string]string{"position": "50° 45.3000'N, 001° 09.0000'W"})
+	if nm.Get("dtw") != "3.846" {
+		t.Errorf("a derived position should not be navigated from got dtw %s", nm.Get("dtw"))
+	}
+}
+
+func TestNavigateFixStatus(t *testing.T) {
+	route, _ := ReadGPXRoute(strings.NewReader(testGPXRoute))
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.SetRoute(route)
+	nm.AttachNavigation()
+
+	nm.Parse("$GPRMC,120000.00,A,5045.6000,N,00112.0000,W,5.0,100.0,150920,1.0,W,A,V*46")
+	if nm.Get("ap_status") != "A" || nm.Get("ap_loran") != "A" {
+		t.Errorf("good fix expected A, A got %s, %s", nm.Get("ap_status"), nm.Get("ap_loran"))
+	}
+
+	// a fix flagged invalid warns by ap_status
+	nm.Update(map[string]string{"status": "V"})
+	nm.Navigate()
+	if nm.Get("ap_status") != "V" || nm.Get("ap_loran") != "A" {
+		t.Errorf("invalid fix expected V, A got %s, %s", nm.Get("ap_status"), nm.Get("ap_loran"))
+	}
+
+	// a stale position warns by both
+	nm.Update(map[string]string{"status": "A", "fix_time": "12:00:20.00", "fix_date": "2020-09-15"})
+	nm.Navigate()
+	if nm.Get("ap_status") != "V" || nm.Get("ap_loran") != "V" {
+		t.Errorf("stale fix expected V, V got %s, %s", nm.Get("ap_status"), nm.Get("ap_loran"))
+	}
+	nm.NavigationFixTimeout(0)
+	nm.Navigate()
+	if nm.Get("ap_status") != "A" || nm.Get("ap_loran") != "A" {
+		t.Errorf("unchecked age expected A, A got %s, %s", nm.Get("ap_status"), nm.Get("ap_loran"))
+	}
+}
+
+func TestNavigateArrival(t *testing.T) {
+	route, _ := ReadGPXRoute(strings.NewReader(testGPXRoute))
+	nm := DefaultSentences().MakeHandle()
+	nm.SetRoute(route)
+	nm.NavigationPreferences(0.2, true, true)
+	nm.AttachNavigation()
+
+	// just past NoMansLand so the next leg to Chichester Bar becomes active
+	nm.Parse("$GPRMC,120000.00,A,5045.0000,N,00105.9000,W,5.0,100.0,150920,1.0,W,A,V*4F")
+	if nm.Route().Leg() != 2 || nm.Get("waypt_id") != "Chichester Bar" || nm.Get("arrived_circle") != "V" {
+		t.Errorf("expected to advance to leg 2 got leg %d %s %s", nm.Route().Leg(), nm.Get("waypt_id"), nm.Get("arrived_circle"))
+	}
+}
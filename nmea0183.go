@@ -49,6 +49,15 @@ func makeConv() *map[string]varTypeStruct {
 		},
 	}
 
+	// the rest of the sentence as comma separated fields eg the waypoint ids of RTE
+	restOfFields := varFormatStruct{
+		fCount: 1,
+		from:   func(pos int, parts *[]string) string { return strings.Join((*parts)[pos:], ",") },
+		to: func(data string) string {
+			return data
+		},
+	}
+
 	compass := varFormatStruct{
 		fCount: 2,
 		from: func(pos int, parts *[]string) string {
@@ -113,6 +122,36 @@ func makeConv() *map[string]varTypeStruct {
 		},
 	}
 
+	// a value held with a minus sign when followed by the negative letter eg S or L
+	signed := func(negative, positive string) varFormatStruct {
+		return varFormatStruct{
+			fCount: 2,
+			from: func(pos int, parts *[]string) string {
+				if strings.EqualFold((*parts)[pos+1], negative) && len((*parts)[pos]) > 0 {
+					return "-" + (*parts)[pos]
+				}
+				return (*parts)[pos]
+			},
+			to: func(data string) string {
+				if len(data) > 0 && data[0] == '-' {
+					return data[1:] + "," + negative
+				}
+				return data + "," + positive
+			},
+		}
+	}
+
+	// a value followed by fixed units
+	units := func(unit string) varFormatStruct {
+		return varFormatStruct{
+			fCount: 2,
+			from:   func(pos int, parts *[]string) string { return (*parts)[pos] },
+			to: func(data string) string {
+				return data + "," + unit
+			},
+		}
+	}
+
 	xte := varFormatStruct{
 		fCount: 3,
 		from: func(pos int, parts *[]string) string {
@@ -128,6 +167,23 @@ func makeConv() *map[string]varTypeStruct {
 		},
 	}
 
+	xteLR := varFormatStruct{
+		fCount: 2,
+		from: func(pos int, parts *[]string) string {
+			if len((*parts)[pos]) == 0 {
+				return ""
+			}
+			return (*parts)[pos+1] + (*parts)[pos] + "N"
+		},
+		to: func(data string) string {
+			l := len(data)
+			if l > 2 {
+				return data[1:l-1] + "," + string(data[0])
+			}
+			return ","
+		},
+	}
+
 	lat := varFormatStruct{
 		fCount: 2,
 		from: func(pos int, parts *[]string) string {
@@ -201,20 +257,29 @@ func makeConv() *map[string]varTypeStruct {
 		"plan_hhmmss.ss":                {fType: "plan time", fConv: timeConv},
 		"A":                             {fType: "status", fConv: copyField},
 		"c--c":                          {fType: "string", fConv: copyField},
+		"c--c,...":                      {fType: "list", fConv: restOfFields},
 		"x.x":                           {fType: "float", fConv: copyField},
 		"-x.x":                          {fType: "signed float", fConv: copyField},
 		"x":                             {fType: "integer", fConv: copyField},
 		"-x":                            {fType: "signed integer", fConv: copyField},
 		"xxx,T":                         {fType: "compass", fConv: compass},
 		"x.x,T":                         {fType: "compass", fConv: compass},
+		"x.x°":                          {fType: "angle", fConv: copyField},
+		"x.x°,l":                        {fType: "signed angle", fConv: signed("L", "R")},
 		"T":                             {fType: "magnetic", fConv: copyField},
 		"x.x,R,N":                       {fType: "cross track error", fConv: xte},
+		"x.x,R":                         {fType: "cross track error", fConv: xteLR},
+		"x.x,N":                         {fType: "distance", fConv: units("N")},
+		"x.x,M":                         {fType: "speed", fConv: units("M")},
+		"x.x,K":                         {fType: "speed", fConv: units("K")},
 		"lat,NS":                        {fType: "lat", fConv: lat},
 		"long,WE":                       {fType: "long", fConv: long},
 		"lat,NS,long,WE":                {fType: "position", fConv: position},
 		"ddmmyy":                        {fType: "date", fConv: date},
 		"plan_ddmmyy":                   {fType: "plan date", fConv: date},
 		"x.x,w":                         {fType: "deviation", fConv: deviation},
+		"x.x,s":                         {fType: "offset", fConv: signed("S", "N")},
+		"x.x,l":                         {fType: "offset", fConv: signed("L", "R")},
 		"DD_day":                        {fType: "day", fConv: copyField},
 		"DD_month":                      {fType: "month", fConv: copyField},
 		"DD_year":                       {fType: "year", fConv: copyField},
@@ -232,10 +297,14 @@ func makeConv() *map[string]varTypeStruct {
 
 //var dateTypeTemplates = []string {"day", "month", "year", "date", "time", "zone"}
 
+// The minutes are kept as received so that the precision of the sentence is preserved
 func latStr(data string) string {
 	if len(data) > 3 {
-		d, _ := strconv.ParseInt(data[:2], 10, 32)
-		m, _ := strconv.ParseFloat(data[2:], 32)
+		d, e1 := strconv.ParseInt(data[:2], 10, 32)
+		m, e2 := strconv.ParseFloat(data[2:], 64)
+		if e1 == nil && e2 == nil && m < 60 && len(data) > 4 && data[4] == '.' {
+			return fmt.Sprintf("%02d° %s'", d, data[2:])
+		}
 		return fmt.Sprintf("%02d° %07.4f'", d, m)
 	}
 	return ""
@@ -243,8 +312,11 @@ func latStr(data string) string {
 
 func longStr(data string) string {
 	if len(data) > 4 {
-		d, _ := strconv.ParseInt(data[:3], 10, 32)
-		m, _ := strconv.ParseFloat(data[3:], 32)
+		d, e1 := strconv.ParseInt(data[:3], 10, 32)
+		m, e2 := strconv.ParseFloat(data[3:], 64)
+		if e1 == nil && e2 == nil && m < 60 && len(data) > 5 && data[5] == '.' {
+			return fmt.Sprintf("%03d° %s'", d, data[3:])
+		}
 		return fmt.Sprintf("%03d° %07.4f'", d, m)
 	}
 	return ""
@@ -330,12 +402,17 @@ func setUp() *Handle {
 
 	set.realTime = true     // false for historic message processing (or No real time clock) and sentences include a date
 	set.autoClearPeriod = 0 // Disabled
+	set.posDecimals = -1    // positions as received
 
 	h.data = make(map[string]string)
 	h.history = make(map[string]int64)
 	h.messageDate = time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
 	h.upDated = time.Now().UTC()
 	h.settings = set
+	h.nav.arrivalRadius = 0.1  // Nm
+	h.nav.fixTimeout = 10000   // ms
+	h.variationTimeout = 60000 // ms
+	h.FilterPreferences(KalmanFilter, 0.001, 5, 0.3)
 
 	return &h
 }
@@ -385,15 +462,15 @@ func LatLongToFloat(params ...string) (float64, float64, error) {
 	/*
 		Give one parameter for a position string with lat, Long or
 		2 parameters to give separate lat and long  strings.
+		Strings may be in any of the PositionFormat formats.
 
-		Returns a 2 floats lat and long and an error.  Minus values for South and West
+		Returns a 2 floats lat and long and an error if either is blank or not valid.
+		Minus values for South and West
 	*/
 	if len(params) < 1 || len(params) > 2 {
 		return 0, 0, fmt.Errorf("illegal number of parmeters given to latlongtofloat")
 	}
 	var lat, long string
-	var symbol byte
-	var retLat, retLong float64 = 0, 0
 
 	if len(params) == 1 {
 		params = strings.SplitN(params[0], ", ", 2)
@@ -403,61 +480,129 @@ func LatLongToFloat(params ...string) (float64, float64, error) {
 		long = params[1]
 	}
 
-	lenL := len(lat)
-	if lenL > 8 {
-		lenL--
-		symbol := lat[lenL]
-		lenL--
-		dlat, _ := strconv.ParseFloat(lat[:2], 64)
-		mlat, _ := strconv.ParseFloat(lat[5:lenL], 64)
-		dlat += mlat / 60
-		if symbol == 'S' {
-			dlat = -dlat
+	latFloat, err := coordToFloat(lat, 'N', 'S')
+	if err != nil {
+		return 0, 0, err
+	}
+	longFloat, err := coordToFloat(long, 'E', 'W')
+	if err != nil {
+		return 0, 0, err
+	}
+	return latFloat, longFloat, nil
+}
+
+// Converts a formatted lat or long to decimal degrees, negative if the last character is
+// the negative symbol and positive if it is the positive symbol
+func coordToFloat(coord string, positive, negative byte) (float64, error) {
+	l := len(coord)
+	if l < 3 || (coord[l-1] != positive && coord[l-1] != negative) {
+		return 0, fmt.Errorf("%q is not a valid coordinate", coord)
+	}
+	symbol := coord[l-1]
+	degStr, rest, _ := strings.Cut(coord[:l-1], "°")
+	deg, err := strconv.ParseFloat(strings.TrimSpace(degStr), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid coordinate", coord)
+	}
+	rest = strings.TrimSpace(rest)
+	if minStr, secStr, found := strings.Cut(rest, "'"); found {
+		mins, err := strconv.ParseFloat(strings.TrimSpace(minStr), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a valid coordinate", coord)
 		}
-		retLat = dlat
-	}
-	lenL = len(long)
-	if lenL > 8 {
-		lenL--
-		symbol = long[lenL]
-		lenL--
-		dlong, _ := strconv.ParseFloat(long[:3], 64)
-		mlong, _ := strconv.ParseFloat(long[6:lenL], 64)
-		dlong += mlong / 60
-		if symbol == 'W' {
-			dlong = -dlong
+		deg += mins / 60
+		if secStr = strings.TrimSpace(strings.TrimSuffix(secStr, "\"")); len(secStr) > 0 {
+			secs, err := strconv.ParseFloat(secStr, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%q is not a valid coordinate", coord)
+			}
+			deg += secs / 3600
 		}
-		retLong = dlong
 	}
-
-	return retLat, retLong, nil
+	if symbol == negative {
+		deg = -deg
+	}
+	return deg, nil
 }
 
+// Formats for positions given as lat and long strings
+type PositionFormat int
+
+const (
+	DegreesDecimalMinutes PositionFormat = iota // 50° 47.3986'N the internal format used in data
+	DecimalDegrees                              // 50.789977°N
+	DegreesMinutesSeconds                       // 50° 47' 23.92"N
+)
+
 func LatLongToString(latFloat, longFloat float64) (string, string, error) {
 	/*
 		Give  2 variables lat and long respectively. Minus values given denote South and West
 		Returns a 2 fromatted strings as lat and long and an error.
 	*/
+	return LatLongToStringFormat(latFloat, longFloat, DegreesDecimalMinutes, 4)
+}
 
-	latAbs := math.Abs(latFloat)
-	latInt := int(latAbs)
-	latMins := (latAbs - float64(latInt)) * 60
-	symbol := "N"
+// As LatLongToString but in the given format with decimals places for the last part ie
+// minutes, degrees or seconds.  With 0 decimals the last part is rounded to a whole number with
+// no decimal point eg 50° 47'N and minutes or seconds which round up to 60 are carried into the
+// part above eg 50° 59.7' gives 51° 00'N
+func LatLongToStringFormat(latFloat, longFloat float64, format PositionFormat, decimals int) (string, string, error) {
+	if decimals < 0 {
+		return "", "", fmt.Errorf("decimals must not be negative")
+	}
+	latSymbol := "N"
 	if latFloat < 0 {
-		symbol = "S"
+		latSymbol = "S"
 	}
-
-	lat := fmt.Sprintf("%02d° %07.4f'%s", latInt, latMins, symbol)
-
-	longAbs := math.Abs(longFloat)
-	longInt := int(longAbs)
-	longMins := (longAbs - float64(longInt)) * 60
-	symbol = "E"
+	longSymbol := "E"
 	if longFloat < 0 {
-		symbol = "W"
+		longSymbol = "W"
+	}
+	lat, err := coordToString(math.Abs(latFloat), 2, format, decimals)
+	if err != nil {
+		return "", "", err
 	}
+	long, _ := coordToString(math.Abs(longFloat), 3, format, decimals)
+	return lat + latSymbol, long + longSymbol, nil
+}
+
+func coordToString(deg float64, degDigits int, format PositionFormat, decimals int) (string, error) {
+	scale := math.Pow(10, float64(decimals))
+	switch format {
+	case DegreesDecimalMinutes:
+		// round before splitting so that minutes never show as 60
+		total := math.Round(deg*60*scale) / scale
+		d := math.Floor(total / 60)
+		m := total - d*60
+		return fmt.Sprintf("%0*d° %0*.*f'", degDigits, int(d), fieldWidth(2, decimals), decimals, m), nil
+	case DecimalDegrees:
+		return fmt.Sprintf("%0*.*f°", fieldWidth(degDigits, decimals), decimals, deg), nil
+	case DegreesMinutesSeconds:
+		total := math.Round(deg*3600*scale) / scale
+		d := math.Floor(total / 3600)
+		m := math.Floor((total - d*3600) / 60)
+		sec := total - d*3600 - m*60
+		return fmt.Sprintf("%0*d° %02d' %0*.*f\"", degDigits, int(d), int(m), fieldWidth(2, decimals), decimals, sec), nil
+	}
+	return "", fmt.Errorf("unknown position format %d", format)
+}
 
-	long := fmt.Sprintf("%03d° %07.4f'%s", longInt, longMins, symbol)
+// Returns the decimal places of minutes which hold a position to at least the precision of
+// decimals places of the last part of format eg 6 places of degrees need 5 places of minutes
+func minuteDecimalsFor(format PositionFormat, decimals int) int {
+	switch format {
+	case DecimalDegrees:
+		return max(decimals-1, 0)
+	case DegreesMinutesSeconds:
+		return decimals + 2
+	}
+	return decimals
+}
 
-	return lat, long, nil
+// Returns the width of a zero padded number with digits before the decimal point
+func fieldWidth(digits int, decimals int) int {
+	if decimals > 0 {
+		return digits + decimals + 1
+	}
+	return digits
 }
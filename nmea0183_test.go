@@ -3,6 +3,7 @@ package nmea0183
 import (
 	"fmt"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -280,3 +281,125 @@ func TestSampleFromRaymarine(t *testing.T) {
 	//verify_sentence("$GPDTM,W84,,0.0000,N,0.0000,E,0,W84*71", t)
 	//verify_sentence("$GPGGA,113157.3,5125.1974,N,00043.4154,W,1,14,,126.8,M,,M,,*7E", t)
 }
+
+func TestHighPrecisionPosition(t *testing.T) {
+	// RTK receivers send 7 decimal places of minutes which must not be lost
+	nm := verify_sentence("$GNRMC,001031.00,A,4404.1399123,N,12118.8602456,W,0.146,,100117,,,A,*50", t)
+	if nm.Get("position") != "44° 04.1399123'N, 121° 18.8602456'W" {
+		t.Errorf("precision lost got %s", nm.Get("position"))
+	}
+	lat, long, _ := nm.LatLongToFloat("position")
+	if math.Abs(lat-(44+4.1399123/60)) > 1e-10 || math.Abs(long+(121+18.8602456/60)) > 1e-10 {
+		t.Errorf("precision lost in float conversion got %.10f %.10f", lat, long)
+	}
+	verify_sentence("$GPWPL,4917.16,N,12310.64,W,003*65", t)
+}
+
+func TestPositionFormats(t *testing.T) {
+	lat, long := 50.78997666666667, -0.9100116666666668
+	formats := []struct {
+		format   PositionFormat
+		decimals int
+		lat      string
+		long     string
+	}{
+		{DegreesDecimalMinutes, 2, "50° 47.40'N", "000° 54.60'W"},
+		{DecimalDegrees, 5, "50.78998°N", "000.91001°W"},
+		{DegreesMinutesSeconds, 1, "50° 47' 23.9\"N", "000° 54' 36.0\"W"},
+		{DegreesDecimalMinutes, 0, "50° 47'N", "000° 55'W"},
+	}
+	for _, f := range formats {
+		latStr, longStr, _ := LatLongToStringFormat(lat, long, f.format, f.decimals)
+		if latStr != f.lat || longStr != f.long {
+			t.Errorf("format %d got %s %s expected %s %s", f.format, latStr, longStr, f.lat, f.long)
+		}
+		latr, longr, _ := LatLongToFloat(latStr, longStr)
+		if math.Abs(latr-lat) > 0.01 || math.Abs(longr-long) > 0.01 {
+			t.Errorf("format %d did not convert back got %f %f", f.format, latr, longr)
+		}
+	}
+
+	latStr, _, _ := LatLongToString(50.99999999, 0)
+	if latStr != "51° 00.0000'N" {
+		t.Errorf("minutes should not round up to 60 got %s", latStr)
+	}
+
+	// with no decimals minutes and seconds which round up to 60 carry into the part above
+	carries := []struct {
+		format    PositionFormat
+		lat, long float64
+		latStr    string
+		longStr   string
+	}{
+		{DegreesDecimalMinutes, 50.995, -0.9999, "51° 00'N", "001° 00'W"},
+		{DegreesDecimalMinutes, 50.9925, -179.9999, "51° 00'N", "180° 00'W"},
+		{DegreesMinutesSeconds, 50.99999, -0.9999, "51° 00' 00\"N", "001° 00' 00\"W"},
+		{DegreesMinutesSeconds, 50.83332, 0.9999, "50° 50' 00\"N", "001° 00' 00\"E"},
+	}
+	for _, c := range carries {
+		latStr, longStr, _ := LatLongToStringFormat(c.lat, c.long, c.format, 0)
+		if latStr != c.latStr || longStr != c.longStr {
+			t.Errorf("format %d with 0 places should carry got %s %s expected %s %s", c.format, latStr, longStr, c.latStr, c.longStr)
+		}
+	}
+	carry := DefaultSentences().MakeHandle()
+	carry.SetPositionFormat(DegreesDecimalMinutes, 0)
+	carry.LatLongToString(50.995, -0.9999, "position")
+	carry.Update(map[string]string{"status": "A"})
+	if rmc, _ := carry.WriteSentence("GP", "RMC"); !strings.Contains(rmc, ",A,5100,N,00100,W,") {
+		t.Errorf("carried position written as %s", rmc)
+	}
+
+	nm := verify_sentence("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75", t)
+	nm.SetPositionFormat(DecimalDegrees, 4)
+	if nm.Get("position") != "50.7900°N, 000.9100°W" {
+		t.Errorf("Get did not use position format got %s", nm.Get("position"))
+	}
+	if rmc, _ := nm.WriteSentence("GP", "RMC"); rmc != "$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75" {
+		t.Errorf("position format must not change written sentences got %s", rmc)
+	}
+
+	// variables are set in minutes to the precision of the position format
+	precisions := []struct {
+		format   PositionFormat
+		decimals int
+		lat      string
+	}{
+		{DegreesDecimalMinutes, 3, "50° 47.399'N"},
+		{DecimalDegrees, 6, "50° 47.39863'N"},
+		{DegreesMinutesSeconds, 1, "50° 47.399'N"},
+	}
+	for _, p := range precisions {
+		nm.SetPositionFormat(p.format, p.decimals)
+		nm.LatLongToString(50.78997716, -0.91001167, "mark_lat", "mark_long")
+		if nm.GetMap()["mark_lat"] != p.lat {
+			t.Errorf("format %d with %d places set %s expected %s", p.format, p.decimals, nm.GetMap()["mark_lat"], p.lat)
+		}
+	}
+}
+
+func TestLatLongToFloatErrors(t *testing.T) {
+	// valid positions convert as they always have, blank or invalid ones used to give 0 and no
+	// error but now give an error
+	conversions := []struct {
+		params    []string
+		lat, long float64
+		valid     bool
+	}{
+		{[]string{"50° 47.3986'N, 000° 54.6007'W"}, 50.789977, -0.910012, true},
+		{[]string{"50° 47.3986'N", "000° 54.6007'W"}, 50.789977, -0.910012, true},
+		{[]string{"33° 52.0000'S, 151° 12.0000'E"}, -33.866667, 151.2, true},
+		{[]string{""}, 0, 0, false},
+		{[]string{"", ""}, 0, 0, false},
+		{[]string{"50° 47.3986'N"}, 0, 0, false},
+		{[]string{"50° 47.3986'N, "}, 0, 0, false},
+		{[]string{"50° 47.3986'X, 000° 54.6007'W"}, 0, 0, false},
+		{[]string{"abc° 47.3986'N, 000° 54.6007'W"}, 0, 0, false},
+	}
+	for _, c := range conversions {
+		lat, long, err := LatLongToFloat(c.params...)
+		if (err == nil) != c.valid || math.Abs(lat-c.lat) > 1e-6 || math.Abs(long-c.long) > 1e-6 {
+			t.Errorf("%q got %f %f %v", c.params, lat, long, err)
+		}
+	}
+}
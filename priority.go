@@ -0,0 +1,175 @@
+package nmea0183
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How the variables given by redundant sources are chosen, see SetSourcePriority
+type Arbitration struct {
+	Keys    []string      // variables taken together from one source eg position, sog and tmg
+	Sources []string      // source names given to ParseFrom or prefixes given to ParsePrefixVar, preferred first
+	Timeout time.Duration // a source which has not given the keys for this long is stale, 0 never
+	Holdoff time.Duration // a preferred source must be healthy this long before it is used again
+}
+
+type arbiter struct {
+	Arbitration
+	selected     string
+	last         map[string]int64 // update time of the keys from each source
+	healthySince map[string]int64
+	values       map[string]map[string]string // variables of the sentences carrying the keys from each source
+	sources      map[string]map[string]Source // the sentence which last gave each of them
+}
+
+// Chooses which source sets the variables given by Keys in the data set.  The first of Sources
+// which is healthy is used; a source is healthy while it is not stale and its status is not V
+// and faa_mode not N.  When the source in use fails the next healthy one takes over at once with
+// its latest values and their update times, and the preferred source is used again once it has
+// been healthy for Holdoff.  All the variables of sentences whose format includes a key, eg
+// status, fix_time and faa_mode of RMC, are taken from the source in use so that they stay
+// consistent.  Every source keeps its own values, see GetFrom, and sources not in the list are
+// not arbitrated.  The source in use is given by SelectedSource and in the variable name_source.
+func (h *Handle) SetSourcePriority(name string, arbitration Arbitration) error {
+	if len(arbitration.Keys) == 0 || len(arbitration.Sources) == 0 {
+		return fmt.Errorf("source priority %s needs keys and sources", name)
+	}
+	if h.arbiters == nil {
+		h.arbiters = make(map[string]*arbiter)
+	}
+	h.arbiters[name] = &arbiter{
+		Arbitration:  arbitration,
+		last:         make(map[string]int64),
+		healthySince: make(map[string]int64),
+		values:       make(map[string]map[string]string),
+		sources:      make(map[string]map[string]Source),
+	}
+	return nil
+}
+
+// Returns the source in use for a source priority set by SetSourcePriority, blank if none yet
+func (h *Handle) SelectedSource(name string) string {
+	if a, ok := h.arbiters[name]; ok {
+		return a.selected
+	}
+	return ""
+}
+
+// Returns the results of the sentence being parsed to merge into the data set with values from
+// sources which have not been selected removed and the values of a newly selected source added,
+// and the sources, with their update times, of values not from the sentence
+func (h *Handle) arbitrate(results map[string]string, timeStamp int64) (map[string]string, map[string]Source) {
+	out := make(map[string]string, len(results))
+	for n, v := range results {
+		out[n] = v
+	}
+	from := make(map[string]Source)
+	src, prefix := h.parsing.Name, h.parsing.prefix
+
+	names := make([]string, 0, len(h.arbiters))
+	for name := range h.arbiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a := h.arbiters[name]
+		listed := false
+		for _, s := range a.Sources {
+			listed = listed || s == src
+		}
+		if !listed || !h.carriesKeys(a, h.parsing.Sentence) {
+			continue
+		}
+		a.last[src] = timeStamp
+		if _, ok := a.values[src]; !ok {
+			a.values[src] = make(map[string]string)
+			a.sources[src] = make(map[string]Source)
+		}
+		for n, v := range results {
+			k := strings.TrimPrefix(n, prefix)
+			a.values[src][k] = v
+			a.sources[src][k] = *h.parsing
+		}
+
+		var best string
+		for _, s := range a.Sources {
+			if h.healthy(a, s, timeStamp) {
+				if _, ok := a.healthySince[s]; !ok {
+					a.healthySince[s] = timeStamp
+				}
+				if best == "" {
+					best = s
+				}
+			} else {
+				delete(a.healthySince, s)
+			}
+		}
+		previous := a.selected
+		switch {
+		case best == "":
+			// nothing is healthy so carry on with the source in use or the first to give data
+			if a.selected == "" {
+				if _, ok := a.last[src]; ok {
+					a.selected = src
+				}
+			}
+		case a.selected == "" || !h.healthy(a, a.selected, timeStamp):
+			a.selected = best
+		case rank(a.Sources, best) < rank(a.Sources, a.selected) &&
+			timeStamp-a.healthySince[best] >= a.Holdoff.Milliseconds():
+			a.selected = best
+		}
+
+		for n, v := range results {
+			k := strings.TrimPrefix(n, prefix)
+			if src == a.selected {
+				out[k] = v
+			} else if prefix == "" {
+				delete(out, k)
+			}
+		}
+		if a.selected != previous {
+			out[name+"_source"] = a.selected
+			if a.selected != src {
+				for k, v := range a.values[a.selected] {
+					out[k] = v
+					from[k] = a.sources[a.selected][k]
+				}
+			}
+		}
+	}
+	return out, from
+}
+
+// Returns true if the format of a sentence type includes any of the keys of an arbiter
+func (h *Handle) carriesKeys(a *arbiter, sentenceType string) bool {
+	for _, n := range h.sentences.formats[sentenceType] {
+		for _, k := range a.Keys {
+			if n == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if a source is not stale and does not report an invalid fix
+func (h *Handle) healthy(a *arbiter, source string, timeStamp int64) bool {
+	last, ok := a.last[source]
+	if !ok || (a.Timeout > 0 && timeStamp-last > a.Timeout.Milliseconds()) {
+		return false
+	}
+	data := h.sourceData[source]
+	return data["status"] != "V" && data["faa_mode"] != "N"
+}
+
+func rank(sources []string, source string) int {
+	for i, s := range sources {
+		if s == source {
+			return i
+		}
+	}
+	return len(sources)
+}
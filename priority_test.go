@@ -0,0 +1,81 @@
+package nmea0183
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Returns an RMC sentence for seconds after 11:00:00 with status and a latitude of minutes
+func rmcAt(talker string, seconds int, status string, minutes float64) string {
+	s := fmt.Sprintf("$%sRMC,1100%02d.00,%s,50%07.4f,N,00100.0000,W,5.0,90.0,150920,,,A", talker, seconds, status, minutes)
+	return s + "*" + checksum(s)
+}
+
+func TestSourcePriority(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	err := nm.SetSourcePriority("gps", Arbitration{
+		Keys: []string{"position", "sog", "tmg"}, Sources: []string{"gps1", "gps2"},
+		Timeout: 3 * time.Second, Holdoff: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		source   string
+		seconds  int
+		status   string
+		minutes  float64
+		selected string
+		position float64
+		fixTime  int // second of the fix time and update time from the source in use
+	}{
+		{"gps1", 0, "A", 1, "gps1", 1, 0},
+		{"gps2", 0, "A", 2, "gps1", 1, 0},
+		{"gps1", 1, "V", 1.1, "gps2", 2, 0}, // invalid so fail over to the last gps2 position
+		{"gps2", 2, "A", 2.2, "gps2", 2.2, 2},
+		{"gps1", 3, "A", 1.3, "gps2", 2.2, 2}, // healthy again but held off
+		{"gps2", 5, "A", 2.5, "gps2", 2.5, 5},
+		{"gps1", 8, "A", 1.8, "gps1", 1.8, 8}, // fail back after the hold off
+		{"gps2", 9, "A", 2.9, "gps1", 1.8, 8},
+		{"gps2", 12, "A", 3.2, "gps2", 3.2, 12}, // gps1 is stale
+	}
+	for i, step := range steps {
+		if _, _, err := nm.ParseFrom(step.source, rmcAt("GP", step.seconds, step.status, step.minutes)); err != nil {
+			t.Fatal(err)
+		}
+		lat, _, _ := nm.LatLongToFloat("position")
+		if nm.SelectedSource("gps") != step.selected || fmt.Sprintf("%.4f", (lat-50)*60) != fmt.Sprintf("%.4f", step.position) {
+			t.Errorf("step %d selected %s position %.4f want %s %.4f", i, nm.SelectedSource("gps"), (lat-50)*60, step.selected, step.position)
+		}
+		// the rest of the sentence and the update times come from the source in use
+		fixTime := fmt.Sprintf("11:00:%02d.00", step.fixTime)
+		updated := time.Date(2020, 9, 15, 11, 0, step.fixTime, 0, time.UTC)
+		if nm.Get("status") != "A" || nm.Get("fix_time") != fixTime || !nm.Date("position").Equal(updated) {
+			t.Errorf("step %d status %s fix time %s updated %s want A %s %s", i, nm.Get("status"), nm.Get("fix_time"), nm.Date("position").UTC(), fixTime, updated)
+		}
+	}
+	if nm.Get("gps_source") != "gps2" {
+		t.Errorf("gps_source got %s", nm.Get("gps_source"))
+	}
+	if source, _ := nm.SourceOf("position"); source.Name != "gps2" {
+		t.Errorf("source of position got %s", source.Name)
+	}
+}
+
+func TestSourcePriorityPrefixed(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.SetSourcePriority("gps", Arbitration{Keys: []string{"position"}, Sources: []string{"a_", "b_"}})
+	nm.ParsePrefixVar(rmcAt("GP", 0, "A", 1), "a_")
+	nm.ParsePrefixVar(rmcAt("GN", 0, "A", 2), "b_")
+	// prefixed variables are kept and the preferred one also sets position
+	if nm.Get("position") != nm.Get("a_position") || nm.Get("b_position") == "" {
+		t.Errorf("position got %s a %s b %s", nm.Get("position"), nm.Get("a_position"), nm.Get("b_position"))
+	}
+	nm.ParsePrefixVar(rmcAt("GP", 1, "V", 1.1), "a_")
+	if nm.Get("position") != nm.Get("b_position") {
+		t.Errorf("failed over position got %s", nm.Get("position"))
+	}
+}
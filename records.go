@@ -0,0 +1,177 @@
+package nmea0183
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Checksum status of a parsed sentence as given in a Record
+const (
+	ChecksumNone  = "none" // sentence had no checksum
+	ChecksumOK    = "ok"
+	ChecksumError = "error"
+)
+
+// A structured record of one parsed sentence as written by an Encoder, one JSON object per line
+type Record struct {
+	Received    time.Time              `json:"received"`
+	MessageTime *time.Time             `json:"message_time,omitempty"`
+	Talker      string                 `json:"talker"`
+	Sentence    string                 `json:"sentence"`
+	Checksum    string                 `json:"checksum"`
+	Raw         string                 `json:"raw"`
+	Prefix      string                 `json:"prefix,omitempty"` // variable prefix given to ParsePrefixVar
+	Variables   map[string]RecordValue `json:"variables"`
+	Derived     bool                   `json:"derived,omitempty"` // values computed by the handle, no sentence
+}
+
+// A variable in a Record given as the internal string format and as a typed value, see TypedValue
+type RecordValue struct {
+	Value string      `json:"value"`
+	Typed interface{} `json:"typed"`
+}
+
+// Writes a JSON Lines record for each sentence parsed by a handle, see Handle.SetEncoder
+type Encoder struct {
+	enc *json.Encoder
+	err error
+}
+
+// Makes an encoder writing JSON Lines records to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{enc: json.NewEncoder(w)}
+}
+
+// Returns the first error met writing records or nil
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Writes a record directly, normally records are written by setting the encoder on a handle
+func (e *Encoder) Encode(rec *Record) error {
+	if err := e.enc.Encode(rec); err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		return err
+	}
+	return nil
+}
+
+func (e *Encoder) encodeSentence(h *Handle, raw, talker, sentenceType, checkStatus, varPrefix string, results map[string]string) {
+	rec := Record{
+		Received:  time.Now().UTC(),
+		Talker:    talker,
+		Sentence:  sentenceType,
+		Checksum:  checkStatus,
+		Raw:       raw,
+		Prefix:    varPrefix,
+		Variables: make(map[string]RecordValue),
+	}
+	unPrefixed := make(map[string]string)
+	for n, v := range results {
+		name := strings.TrimPrefix(n, varPrefix)
+		unPrefixed[name] = v
+		rec.Variables[n] = RecordValue{Value: v, Typed: typedValue(h.sentences.variables[name], v)}
+	}
+	if messageDate, found := h.messageDateFrom(unPrefixed); found {
+		rec.MessageTime = &messageDate
+	} else if h.messageDate.Year() > 0 {
+		messageDate = h.messageDate
+		rec.MessageTime = &messageDate
+	}
+	e.Encode(&rec)
+}
+
+func (e *Encoder) encodeDerived(h *Handle, values map[string]string) {
+	rec := Record{
+		Received:  time.Now().UTC(),
+		Checksum:  ChecksumNone,
+		Variables: make(map[string]RecordValue),
+		Derived:   true,
+	}
+	for n, v := range values {
+		rec.Variables[n] = RecordValue{Value: v, Typed: typedValue(h.sentences.variables[n], v)}
+	}
+	if h.messageDate.Year() > 0 {
+		messageDate := h.messageDate
+		rec.MessageTime = &messageDate
+	}
+	e.Encode(&rec)
+}
+
+// Sets an encoder to write a record of every sentence processed by ParseToMap and so by Parse
+// and ParsePrefixVar.  Sentences with a checksum error are recorded with Checksum set to "error".
+// Values derived by the handle eg by TrueWind are recorded with Derived set and no sentence.
+// Give nil to stop recording.
+func (h *Handle) SetEncoder(enc *Encoder) {
+	h.encoder = enc
+}
+
+// Reads JSON Lines records written by an Encoder
+type Decoder struct {
+	dec *json.Decoder
+}
+
+// Makes a decoder reading records from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// Returns the next record or io.EOF when there are no more
+func (d *Decoder) Decode() (*Record, error) {
+	var rec Record
+	if err := d.dec.Decode(&rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Merges the variables of a record into the data set through the same steps as Update, so
+// datums, late messages, auto clear and validity are handled as when it was received.  Variables
+// parsed with ParsePrefixVar keep the prefix as their source as they did when parsed.  Records
+// with a checksum error are ignored as Parse would have done and derived records are merged as
+// derived values.  The update time of each variable is the received time in real time mode and
+// the message time when processing historic data.
+func (h *Handle) UpdateRecord(rec *Record) {
+	if rec.Checksum == ChecksumError {
+		return
+	}
+	results := make(map[string]string)
+	for n, v := range rec.Variables {
+		results[n] = v.Value
+	}
+	if rec.Derived {
+		timeStamp := rec.Received.UnixMilli()
+		if !h.settings.realTime {
+			timeStamp = h.messageDate.UnixMilli()
+		}
+		h.setVarsAt(results, timeStamp)
+		return
+	}
+	h.parsing = &Source{Name: rec.Prefix, Talker: rec.Talker, Sentence: rec.Sentence, Raw: rec.Raw, prefix: rec.Prefix}
+	h.updateReceived(h.applyValidity(rec.Sentence, results, rec.Prefix), rec.Received.UTC())
+	h.parsing = nil
+}
+
+// Rebuilds the data set of a handle from a JSON Lines file written by an Encoder.
+// Returns the number of records read and any error
+func (h *Handle) ReadRecords(r io.Reader) (int, error) {
+	dec := NewDecoder(r)
+	count := 0
+	for {
+		rec, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("record %d could not be read: %w", count+1, err)
+		}
+		h.UpdateRecord(rec)
+		count++
+	}
+}
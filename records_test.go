@@ -0,0 +1,144 @@
+package nmea0183
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncodeRecords(t *testing.T) {
+	var buf bytes.Buffer
+	nm := DefaultSentences().MakeHandle()
+	nm.SetEncoder(NewEncoder(&buf))
+
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Parse("$HCHDM,172.5,M*29")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records got %d", len(lines))
+	}
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("record is not JSON: %s", err)
+	}
+	if rec["talker"] != "GP" || rec["sentence"] != "rmc" || rec["checksum"] != "ok" {
+		t.Errorf("record header incorrect got %v", rec)
+	}
+	if rec["message_time"] != "2020-09-15T11:09:10.59Z" {
+		t.Errorf("message time incorrect got %v", rec["message_time"])
+	}
+	vars := rec["variables"].(map[string]interface{})
+	sog := vars["sog"].(map[string]interface{})
+	if sog["value"] != "0.08" || sog["typed"] != 0.08 {
+		t.Errorf("sog incorrectly recorded got %v", sog)
+	}
+	position := vars["position"].(map[string]interface{})["typed"].(map[string]interface{})
+	if position["long"].(float64) > -0.91 || position["long"].(float64) < -0.92 {
+		t.Errorf("position incorrectly typed got %v", position)
+	}
+	if !strings.Contains(lines[1], `"checksum":"error"`) {
+		t.Errorf("bad checksum not recorded got %s", lines[1])
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	var buf bytes.Buffer
+	nm := DefaultSentences().MakeHandle()
+	nm.SetEncoder(NewEncoder(&buf))
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	nm.Parse("$SSDPT,2.8,-0.7")
+	nm.Parse("$HCHDM,172.5,M*29")
+
+	nm2 := DefaultSentences().MakeHandle()
+	nm2.Preferences(0, false)
+	count, err := nm2.ReadRecords(&buf)
+	if err != nil || count != 3 {
+		t.Fatalf("expected 3 records without error got %d %v", count, err)
+	}
+	if nm2.Get("position") != nm.Get("position") || nm2.Get("dbt") != "2.8" {
+		t.Errorf("handle not rebuilt got %v", nm2.GetMap())
+	}
+	if _, ok := nm2.GetMap()["hdm"]; ok {
+		t.Error("record with checksum error should not update data")
+	}
+	if nm2.Date("dbt").UTC().Format("2006-01-02") != "2020-09-15" {
+		t.Errorf("historic update time should come from message time got %s", nm2.Date("dbt"))
+	}
+}
+
+func TestRecordsMatchParse(t *testing.T) {
+	// replaying records gives the same data as parsing the sentences they were made from
+	sentences := []string{
+		"$GPRMC,110910.59,A,5128.6418,N,00000.0090,E,0.08,0.19,150920,0.24,W,D,V*69",
+		"$GPDTM,OGB,,0.0000,N,0.0000,E,0.0,W84*7E",
+		"$SSDPT,2.8,-0.7",
+		"$GPRMC,110950.59,A,5128.6418,N,00000.0090,E,0.08,0.19,150920,0.24,W,D,V",
+		"$HCHDM,172.5,M*28",
+	}
+	setUp := func() *Handle {
+		nm := DefaultSentences().MakeHandle()
+		nm.Preferences(30, false)
+		nm.NormaliseDatum(true)
+		return nm
+	}
+	var buf bytes.Buffer
+	parsed := setUp()
+	parsed.SetEncoder(NewEncoder(&buf))
+	for _, s := range sentences {
+		parsed.Parse(s)
+	}
+	replayed := setUp()
+	if _, err := replayed.ReadRecords(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parsed.GetMap()["dbt"]; ok {
+		t.Error("dbt should have been cleared")
+	}
+	if parsed.Get("position_datum") != "W84" {
+		t.Errorf("position should be normalised got %s", parsed.Get("position_datum"))
+	}
+	if len(replayed.GetMap()) != len(parsed.GetMap()) {
+		t.Errorf("replayed data %v differs from parsed %v", replayed.GetMap(), parsed.GetMap())
+	}
+	for k, v := range parsed.GetMap() {
+		if replayed.Get(k) != v || !replayed.Date(k).Equal(parsed.Date(k)) {
+			t.Errorf("%s replayed %s at %s parsed %s at %s", k, replayed.Get(k), replayed.Date(k), v, parsed.Date(k))
+		}
+	}
+}
+
+func TestRecordsMatchParsePrefixVar(t *testing.T) {
+	// prefixed variables are replayed with their prefix so validity and formats still apply
+	setUp := func() *Handle {
+		nm := DefaultSentences().MakeHandle()
+		nm.Preferences(0, false)
+		nm.ValidityPreferences(DropInvalid)
+		return nm
+	}
+	var buf bytes.Buffer
+	parsed := setUp()
+	parsed.SetEncoder(NewEncoder(&buf))
+	parsed.ParsePrefixVar("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,359.0,150920,0.24,W,D,V", "a_")
+	parsed.ParsePrefixVar("$GPRMC,110911.59,V,5048.0000,N,00054.6007,W,0.08,10.0,150920,0.24,W,D,V", "a_")
+	replayed := setUp()
+	if _, err := replayed.ReadRecords(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Get("a_tmg") != "359.0" || parsed.Get("a_status") != "V" {
+		t.Errorf("invalid a_tmg should be dropped got %s %s", parsed.Get("a_tmg"), parsed.Get("a_status"))
+	}
+	for k, v := range parsed.GetMap() {
+		if replayed.Get(k) != v {
+			t.Errorf("%s replayed %s parsed %s", k, replayed.Get(k), v)
+		}
+	}
+	source, _ := replayed.SourceOf("a_tmg")
+	if want, _ := parsed.SourceOf("a_tmg"); source.Name != want.Name || source.prefix != "a_" {
+		t.Errorf("replayed source %+v parsed %+v", source, want)
+	}
+	if !replayed.isCircular("a_tmg") || replayed.GetFrom("a_", "tmg") != "359.0" {
+		t.Errorf("replayed a_tmg should keep its format and source got %s", replayed.GetFrom("a_", "tmg"))
+	}
+}
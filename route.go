@@ -0,0 +1,213 @@
+package nmea0183
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A named waypoint, lat and long are decimal degrees with minus values for South and West
+type Waypoint struct {
+	Name string
+	Lat  float64
+	Long float64
+}
+
+// A route of named waypoints with an active leg from the origin waypoint to the destination
+type Route struct {
+	Name      string
+	Waypoints []Waypoint
+	active    int // index of the destination waypoint of the active leg
+}
+
+// Makes a route with the first leg active
+func NewRoute(name string, waypoints []Waypoint) *Route {
+	return &Route{Name: name, Waypoints: waypoints, active: 1}
+}
+
+type gpxRouteFile struct {
+	Routes []struct {
+		Name   string        `xml:"name"`
+		Points []gpxWaypoint `xml:"rtept"`
+	} `xml:"rte"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name"`
+}
+
+// Reads the first route from a GPX file, if the file has no route its waypoints are
+// used in order.  Waypoints without a name are named by their number in the route.
+func ReadGPXRoute(r io.Reader) (*Route, error) {
+	var file gpxRouteFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("gpx route could not be read: %w", err)
+	}
+	name := ""
+	points := file.Waypoints
+	if len(file.Routes) > 0 {
+		name = file.Routes[0].Name
+		points = file.Routes[0].Points
+	}
+	if len(points) < 2 {
+		return nil, fmt.Errorf("gpx route must have at least 2 waypoints")
+	}
+	waypoints := make([]Waypoint, len(points))
+	for i, p := range points {
+		waypoints[i] = Waypoint{Name: p.Name, Lat: p.Lat, Long: p.Lon}
+		if len(p.Name) == 0 {
+			waypoints[i].Name = fmt.Sprintf("%03d", i+1)
+		}
+	}
+	return NewRoute(name, waypoints), nil
+}
+
+// Returns the origin and destination waypoints of the active leg and false if there is no active leg
+func (r *Route) ActiveLeg() (Waypoint, Waypoint, bool) {
+	if r.active < 1 || r.active >= len(r.Waypoints) {
+		return Waypoint{}, Waypoint{}, false
+	}
+	return r.Waypoints[r.active-1], r.Waypoints[r.active], true
+}
+
+// Returns the number of the active leg starting at 1 for the leg from the first waypoint
+func (r *Route) Leg() int {
+	return r.active
+}
+
+// Makes the given leg active, leg 1 is from the first waypoint to the second
+func (r *Route) SetLeg(leg int) error {
+	if leg < 1 || leg >= len(r.Waypoints) {
+		return fmt.Errorf("route has no leg %d", leg)
+	}
+	r.active = leg
+	return nil
+}
+
+// Makes the next leg active and returns false if the last leg was already active
+func (r *Route) Advance() bool {
+	if r.active+1 >= len(r.Waypoints) {
+		return false
+	}
+	r.active++
+	return true
+}
+
+// Sets the route to follow and fills the waypoint variables from its active leg, see AdvanceLeg
+func (h *Handle) SetRoute(r *Route) {
+	h.route = r
+	h.setLegVars()
+}
+
+// Returns the route being followed or nil
+func (h *Handle) Route() *Route {
+	return h.route
+}
+
+// Makes the next leg of the route active, updating the waypoint variables.
+// Returns false if there is no route or the last leg was already active.
+func (h *Handle) AdvanceLeg() bool {
+	if h.route == nil || !h.route.Advance() {
+		return false
+	}
+	h.setLegVars()
+	return true
+}
+
+// Sets the variables used by APB, APA, AAM, RMB etc from the active leg:
+// waypt_id and did are the destination waypoint, origin_id the origin waypoint,
+// wpt_position the destination position and bearing_origin_to_waypt the leg bearing.
+// Arrival and passed status are reset to V
+func (h *Handle) setLegVars() {
+	if h.route == nil {
+		return
+	}
+	origin, dest, ok := h.route.ActiveLeg()
+	if !ok {
+		return
+	}
+	bearing := Bearing(origin.Lat, origin.Long, dest.Lat, dest.Long)
+	h.setVars(map[string]string{
+		"waypt_id":                dest.Name,
+		"did":                     dest.Name,
+		"origin_id":               origin.Name,
+		"wpt_position":            positionStr(dest.Lat, dest.Long),
+		"bearing_origin_to_waypt": compassStr(bearing, "T"),
+		"arrived_circle":          "V",
+		"passed_waypt":            "V",
+	})
+}
+
+// Returns the sentences describing the route: a WPL for each waypoint followed by RTE sentences
+// listing the waypoint ids.  routeType is "c" for the complete route or "w" for a working route
+// starting at the origin of the active leg.  Names are stripped of characters NMEA does not allow.
+func (h *Handle) WriteRoute(manCode string, routeType string) ([]string, error) {
+	if h.route == nil {
+		return nil, fmt.Errorf("no route has been set")
+	}
+	waypoints := h.route.Waypoints
+	if routeType == "w" && h.route.active > 0 {
+		waypoints = waypoints[h.route.active-1:]
+	}
+
+	var sentences []string
+	var ids []string
+	for _, wpt := range waypoints {
+		id := nmeaName(wpt.Name)
+		ids = append(ids, id)
+		wpl, err := h.WriteSentenceWith(manCode, "WPL", map[string]string{
+			"wpl_position": positionStr(wpt.Lat, wpt.Long),
+			"wpl_id":       id,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sentences = append(sentences, wpl)
+	}
+
+	// pack as many ids as fit into each RTE sentence keeping within 80 characters before <CR><LF>
+	routeID := nmeaName(h.route.Name)
+	maxLen := 80 - len("$RTE,99,99,c,,*hh") - len(manCode) - len(routeID)
+	var groups []string
+	group := ""
+	for _, id := range ids {
+		if len(group) > 0 && len(group)+1+len(id) > maxLen {
+			groups = append(groups, group)
+			group = ""
+		}
+		if len(group) > 0 {
+			group += ","
+		}
+		group += id
+	}
+	groups = append(groups, group)
+
+	for i, group := range groups {
+		rte, err := h.WriteSentenceWith(manCode, "RTE", map[string]string{
+			"rte_count":  strconv.Itoa(len(groups)),
+			"rte_num":    strconv.Itoa(i + 1),
+			"rte_type":   routeType,
+			"rte_id":     routeID,
+			"rte_waypts": group,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sentences = append(sentences, rte)
+	}
+	return sentences, nil
+}
+
+func nmeaName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ',' || r == '*' || r == '$' || r == '!' || r < ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, name)
+}
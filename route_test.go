@@ -0,0 +1,89 @@
+package nmea0183
+
+import (
+	"strings"
+	"testing"
+)
+
+const testGPXRoute = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <rte>
+    <name>Solent</name>
+    <rtept lat="50.7667" lon="-1.3000"><name>Cowes</name></rtept>
+    <rtept lat="50.7500" lon="-1.1000"><name>NoMansLand</name></rtept>
+    <rtept lat="50.7833" lon="-0.9500"><name>Chichester Bar</name></rtept>
+  </rte>
+</gpx>`
+
+func TestRoute(t *testing.T) {
+	route, err := ReadGPXRoute(strings.NewReader(testGPXRoute))
+	if err != nil {
+		t.Fatalf("route not read %s", err)
+	}
+	if route.Name != "Solent" || len(route.Waypoints) != 3 || route.Waypoints[2].Long != -0.95 {
+		t.Errorf("route incorrectly read got %v", route)
+	}
+
+	nm := DefaultSentences().MakeHandle()
+	nm.SetRoute(route)
+	if nm.Get("waypt_id") != "NoMansLand" || nm.Get("origin_id") != "Cowes" ||
+		nm.Get("wpt_position") != "50° 45.0000'N, 001° 06.0000'W" || nm.Get("bearing_origin_to_waypt") != "97.4°T" {
+		t.Errorf("leg variables incorrect got %v", nm.GetMap())
+	}
+
+	if !nm.AdvanceLeg() || nm.Get("did") != "Chichester Bar" || nm.AdvanceLeg() {
+		t.Errorf("advance leg failed got %s", nm.Get("did"))
+	}
+
+	sentences, err := nm.WriteRoute("GP", "c")
+	if err != nil {
+		t.Fatalf("route not written %s", err)
+	}
+	expected := []string{
+		"$GPWPL,5046.0020,N,00118.0000,W,Cowes*19",
+		"$GPWPL,5045.0000,N,00106.0000,W,NoMansLand*6D",
+		"$GPWPL,5046.9980,N,00057.0000,W,Chichester Bar*19",
+		"$GPRTE,1,1,c,Solent,Cowes,NoMansLand,Chichester Bar*03",
+	}
+	if strings.Join(sentences, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("route sentences incorrect got %v", sentences)
+	}
+
+	working, _ := nm.WriteRoute("GP", "w")
+	if working[len(working)-1] != "$GPRTE,1,1,w,Solent,NoMansLand,Chichester Bar*76" {
+		t.Errorf("working route incorrect got %v", working)
+	}
+}
+
+func TestRouteSplitsRTE(t *testing.T) {
+	var waypoints []Waypoint
+	for i := 0; i < 20; i++ {
+		waypoints = append(waypoints, Waypoint{Name: "WAYPOINT" + string(rune('A'+i)), Lat: 50, Long: -1})
+	}
+	nm := DefaultSentences().MakeHandle()
+	nm.SetRoute(NewRoute("LONG", waypoints))
+	sentences, _ := nm.WriteRoute("GP", "c")
+	rte := sentences[20:]
+	if len(rte) < 2 || !strings.HasPrefix(rte[0], "$GPRTE,4,1,c,LONG,WAYPOINTA,") {
+		t.Errorf("long route should be split got %v", rte)
+	}
+	for _, s := range rte {
+		if len(s) > 80 {
+			t.Errorf("sentence too long %d %s", len(s), s)
+		}
+	}
+}
+
+func TestRouteParsesRTE(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	rte := "$GPRTE,1,1,c,Solent,Cowes,NoMansLand,Chichester Bar*03"
+	if _, _, err := nm.Parse(rte); err != nil {
+		t.Fatal(err)
+	}
+	if nm.Get("rte_id") != "Solent" || nm.Get("rte_waypts") != "Cowes,NoMansLand,Chichester Bar" {
+		t.Errorf("all waypoint ids should be parsed got %s %s", nm.Get("rte_id"), nm.Get("rte_waypts"))
+	}
+	if s, err := nm.WriteSentence("GP", "RTE"); s != rte {
+		t.Errorf("RTE should be written back got %s %v", s, err)
+	}
+}
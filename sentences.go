@@ -10,6 +10,7 @@ import (
 type Sentences struct {
 	formats   map[string][]string
 	variables map[string]string
+	validity  map[string]ValidityRule
 }
 
 // Pass a map containing a list of variable names for each sentence definition
@@ -58,6 +59,15 @@ func (sent *Sentences) AddVariable(key string, varFormat string) {
 	sent.variables[key] = varFormat
 }
 
+// Sets the rule saying when variables parsed from a sentence are valid, see ValidityRule
+// This would be used instead of an external definitions file
+func (sent *Sentences) AddValidity(key string, rule ValidityRule) {
+	if sent.validity == nil {
+		sent.validity = make(map[string]ValidityRule)
+	}
+	sent.validity[key] = rule
+}
+
 // Loads sentence definitions from a file
 // If no parameters uses defaults.
 // 1st parameter is the path followed by the file name and format
@@ -83,6 +93,10 @@ func (sent *Sentences) Load(setting ...string) error {
 
 	sent.formats = viper.GetStringMapStringSlice("formats")
 	sent.variables = viper.GetStringMapString("variables")
+	sent.validity = nil
+	if err = viper.UnmarshalKey("validity", &sent.validity); err != nil {
+		err = fmt.Errorf("validity rules in config file are not valid: %w", err)
+	}
 
 	return err
 }
@@ -101,6 +115,7 @@ func (sent *Sentences) SaveLoadDefault(setting ...string) {
 
 	viper.SetDefault("formats", GetDefaultFormats())
 	viper.SetDefault("variables", GetDefaultVars())
+	viper.SetDefault("validity", validityConfig(GetDefaultValidity()))
 	viper.ReadInConfig() // Find and read the config file
 
 	//Don't overwrite if file exists
@@ -109,5 +124,25 @@ func (sent *Sentences) SaveLoadDefault(setting ...string) {
 	//}
 	sent.formats = viper.GetStringMapStringSlice("formats")
 	sent.variables = viper.GetStringMapString("variables")
+	sent.validity = nil
+	viper.UnmarshalKey("validity", &sent.validity)
+}
 
+// Returns validity rules in the form written to a definitions file
+func validityConfig(rules map[string]ValidityRule) map[string]interface{} {
+	config := make(map[string]interface{})
+	for name, rule := range rules {
+		r := map[string]interface{}{"field": rule.Field}
+		if len(rule.Valid) > 0 {
+			r["valid"] = rule.Valid
+		}
+		if len(rule.Invalid) > 0 {
+			r["invalid"] = rule.Invalid
+		}
+		if len(rule.Keys) > 0 {
+			r["keys"] = rule.Keys
+		}
+		config[name] = r
+	}
+	return config
 }
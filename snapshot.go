@@ -0,0 +1,170 @@
+package nmea0183
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The JSON form of a handle's state, data variables are kept in the internal string format
+type snapshot struct {
+	Data        map[string]string `json:"data"`
+	History     map[string]int64  `json:"history"`
+	MessageDate time.Time         `json:"message_date"`
+	Saved       time.Time         `json:"saved"`
+}
+
+type checkpointState struct {
+	last int64 // millisecond time of the last checkpoint
+	err  error
+}
+
+// Returns the data set, the time each variable was updated and the message date as JSON.
+// Use Restore to reload a handle from the result eg after a restart.
+func (h *Handle) Snapshot() ([]byte, error) {
+	snap := snapshot{
+		Data:        h.data,
+		History:     h.history,
+		MessageDate: h.messageDate,
+		Saved:       time.Now().UTC(),
+	}
+	return json.MarshalIndent(snap, "", "  ")
+}
+
+// Replaces the data set, update times and message date with those held in a JSON snapshot.
+// maxAge in seconds discards restored variables older than maxAge (<= 0 keeps all).
+// Age is measured from the processor clock in real time mode or from the restored
+// message date when processing historic data.
+// State kept about the variables replaced is cleared: sources and their data, invalid marks,
+// value histories, statistics, source priority selections, the current epoch, the track of dead
+// reckoning and the filter, current and true wind averages and the count of late messages.
+// Preferences such as KeepHistory limits, SetSourcePriority and FilterPreferences are kept.
+func (h *Handle) Restore(snapJSON []byte, maxAge int64) error {
+	var snap snapshot
+	if err := json.Unmarshal(snapJSON, &snap); err != nil {
+		return fmt.Errorf("snapshot could not be read: %w", err)
+	}
+
+	h.data = make(map[string]string)
+	h.history = make(map[string]int64)
+	h.clearVariableState()
+	if !snap.MessageDate.IsZero() {
+		h.messageDate = snap.MessageDate
+	}
+
+	timeBefore := int64(0)
+	if maxAge > 0 {
+		timeBefore = h.timeNow() - maxAge*1000
+	}
+	for k, v := range snap.Data {
+		updated, ok := snap.History[k]
+		if maxAge > 0 && (!ok || updated < timeBefore) {
+			continue
+		}
+		h.data[k] = v
+		h.history[k] = updated
+	}
+	return nil
+}
+
+// clears state kept about the variables in data so that it does not outlive them
+func (h *Handle) clearVariableState() {
+	h.sources, h.sourceData, h.invalid = nil, nil, nil
+	for _, l := range h.valueLogs {
+		*l = valueLog{limits: l.limits}
+	}
+	for _, t := range h.trackers {
+		t.samples = nil
+		for i := range t.damping {
+			t.damping[i] = dampState{period: t.damping[i].period}
+		}
+	}
+	for _, a := range h.arbiters {
+		*a = arbiter{
+			Arbitration:  a.Arbitration,
+			last:         make(map[string]int64),
+			healthySince: make(map[string]int64),
+			values:       make(map[string]map[string]string),
+			sources:      make(map[string]map[string]Source),
+		}
+	}
+	h.epoch.started, h.epoch.fixTime, h.epoch.last, h.epoch.variables, h.epoch.complete = false, "", 0, nil, nil
+	h.dr = drState{useWater: h.dr.useWater, overwrite: h.dr.overwrite, timeout: h.dr.timeout}
+	h.filter = filterState{kind: h.filter.kind, processNoise: h.filter.processNoise,
+		positionNoise: h.filter.positionNoise, speedNoise: h.filter.speedNoise}
+	h.current.samples = nil
+	h.wind = windState{useSOG: h.wind.useSOG, trueHeading: h.wind.trueHeading, damping: h.wind.damping}
+	h.late.discarded = 0
+}
+
+// Writes a snapshot to the given file. The file is first written to a temporary file in
+// the same directory and then renamed so a reader or power failure never sees a partial file.
+func (h *Handle) SaveSnapshot(fileName string) error {
+	snapJSON, err := h.Snapshot()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("snapshot file could not be created: %w", err)
+	}
+	tmpName := tmp.Name()
+	_, err = tmp.Write(snapJSON)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmpName, fileName)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("snapshot file could not be written: %w", err)
+	}
+	return nil
+}
+
+// Reads a snapshot file written by SaveSnapshot or Checkpoint and restores the handle from it.
+// See Restore for the meaning of maxAge
+func (h *Handle) LoadSnapshot(fileName string, maxAge int64) error {
+	snapJSON, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("snapshot file could not be read: %w", err)
+	}
+	return h.Restore(snapJSON, maxAge)
+}
+
+// Sets Update to save a snapshot to fileName every period seconds.
+// Give a blank file name or period <= 0 to disable checkpoints.
+// Checkpoints are written as sentences are parsed so no sentences means no checkpoints.
+func (h *Handle) Checkpoint(fileName string, period int64) {
+	if len(fileName) == 0 || period < 1 {
+		h.settings.checkpointFile = ""
+		h.settings.checkpointPeriod = 0
+		return
+	}
+	h.settings.checkpointFile = fileName
+	h.settings.checkpointPeriod = period * 1000
+	h.checkpoint.last = h.timeNow()
+}
+
+// Returns the error from the last checkpoint written by Update or nil if it succeeded
+func (h *Handle) CheckpointError() error {
+	return h.checkpoint.err
+}
+
+func (h *Handle) checkpointIfDue() {
+	if h.settings.checkpointPeriod <= 0 {
+		return
+	}
+	timeNow := h.timeNow()
+	if timeNow-h.checkpoint.last < h.settings.checkpointPeriod {
+		return
+	}
+	h.checkpoint.last = timeNow
+	h.checkpoint.err = h.SaveSnapshot(h.settings.checkpointFile)
+}
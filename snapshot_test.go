@@ -0,0 +1,137 @@
+package nmea0183
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+
+	snap, err := nm.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot failed: %s", err)
+	}
+
+	nm2 := DefaultSentences().MakeHandle()
+	if err := nm2.Restore(snap, 0); err != nil {
+		t.Fatalf("restore failed: %s", err)
+	}
+	if nm2.Get("position") != nm.Get("position") || !nm2.Date("sog").Equal(nm.Date("sog")) {
+		t.Errorf("restored data differs got %s updated %s", nm2.Get("position"), nm2.Date("sog"))
+	}
+	if !nm2.messageDate.Equal(nm.messageDate) {
+		t.Errorf("message date not restored got %s expected %s", nm2.messageDate, nm.messageDate)
+	}
+
+	rmc, _ := nm2.WriteSentence("GP", "RMC")
+	if rmc != "$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75" {
+		t.Errorf("restored handle wrote %s", rmc)
+	}
+}
+
+func TestRestoreUsedHandle(t *testing.T) {
+	// a snapshot restored onto a handle in use replaces the state kept about its variables
+	nm := DefaultSentences().MakeHandle()
+	nm.Parse("$SSDPT,2.8,-0.7")
+	snap, _ := nm.Snapshot()
+
+	used := DefaultSentences().MakeHandle()
+	used.Preferences(0, false)
+	used.ValidityPreferences(MarkInvalid)
+	used.KeepHistory(HistoryLimits{Count: 10}, "position")
+	used.EpochPreferences(0)
+	used.AddStatistics("sog", time.Minute)
+	used.ParseFrom("gps1", rmcAt("GP", 11, "V", 1))
+	if err := used.Restore(snap, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := used.SourceOf("position"); ok || len(used.SourceNames()) != 0 {
+		t.Errorf("sources should be cleared got %v", used.SourceNames())
+	}
+	if !used.Valid("position") || len(used.Values("position", time.Time{}, time.Now())) != 0 {
+		t.Error("invalid marks and value history should be cleared")
+	}
+	if _, err := used.Statistics("sog", time.Minute); err == nil {
+		t.Error("statistics should be cleared")
+	}
+	if used.ValueState("position") != Absent || used.Get("dbt") != "2.8" {
+		t.Errorf("restored data got position %v dbt %s", used.ValueState("position"), used.Get("dbt"))
+	}
+	// preferences are kept
+	used.ParseFrom("gps1", rmcAt("GP", 12, "A", 2))
+	if len(used.Values("position", time.Time{}, time.Now())) != 1 || used.SourceNames()[0] != "gps1" {
+		t.Errorf("history should be kept again got %v", used.Values("position", time.Time{}, time.Now()))
+	}
+	used.CloseEpoch()
+	if epoch, ok := used.Epoch(); !ok || len(epoch.Variables) == 0 || epoch.Data["dbt"] != "2.8" {
+		t.Errorf("epoch should only hold data since the restore got %+v", epoch)
+	}
+}
+
+func TestRestoreWithFilter(t *testing.T) {
+	// the first fix after a restore starts a new track rather than being blended with the old one
+	nm := DefaultSentences().MakeHandle()
+	nm.Parse("$SSDPT,2.8,-0.7")
+	snap, _ := nm.Snapshot()
+
+	used := DefaultSentences().MakeHandle()
+	used.Preferences(0, false)
+	used.FilterPreferences(AlphaBetaFilter, 0, 0, 0)
+	used.AttachFilter()
+	used.LatePreferences(RejectLate, 0)
+	used.Parse(rmcAt("GP", 10, "A", 1))
+	used.Parse(rmcAt("GP", 11, "A", 1.01))
+	used.Parse(rmcAt("GP", 5, "A", 1))
+	if used.DiscardedCount() != 1 {
+		t.Fatalf("expected a late message got %d", used.DiscardedCount())
+	}
+	if err := used.Restore(snap, 0); err != nil {
+		t.Fatal(err)
+	}
+	if used.DiscardedCount() != 0 {
+		t.Errorf("late count should be cleared got %d", used.DiscardedCount())
+	}
+	used.Parse(rmcAt("GP", 12, "A", 5))
+	if used.Get("position_smooth") != "50° 05.0000'N, 001° 00.0000'W" || used.filter.kind != AlphaBetaFilter {
+		t.Errorf("filter should start again from the first fix got %s", used.Get("position_smooth"))
+	}
+}
+
+func TestSnapshotMaxAge(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Parse("$HCHDM,172.5,M*28")
+	nm.Parse("$SSDPT,2.8,-0.7")
+	nm.history["hdm"] = time.Now().Add(-time.Hour).UnixMilli()
+
+	snap, _ := nm.Snapshot()
+	nm2 := DefaultSentences().MakeHandle()
+	nm2.Restore(snap, 60)
+	if _, ok := nm2.GetMap()["hdm"]; ok {
+		t.Error("hdm older than max age should not be restored")
+	}
+	if nm2.Get("dbt") != "2.8" {
+		t.Errorf("dbt should be restored got %s", nm2.Get("dbt"))
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "nmea_snapshot.json")
+	nm := DefaultSentences().MakeHandle()
+	nm.Checkpoint(fileName, 1)
+	nm.checkpoint.last -= 2000
+	nm.Parse("$HCHDM,172.5,M*28")
+	if err := nm.CheckpointError(); err != nil {
+		t.Fatalf("checkpoint failed: %s", err)
+	}
+
+	nm2 := DefaultSentences().MakeHandle()
+	if err := nm2.LoadSnapshot(fileName, 0); err != nil {
+		t.Fatalf("load snapshot failed: %s", err)
+	}
+	if nm2.Get("hdm") != "172.5°M" {
+		t.Errorf("checkpoint did not save hdm got %s", nm2.Get("hdm"))
+	}
+}
@@ -0,0 +1,110 @@
+package nmea0183
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Where the value of a variable came from
+type Source struct {
+	Name     string          // input source given to ParseFrom or the prefix given to ParsePrefixVar, blank for Parse
+	Talker   string          // talker id eg GP
+	Sentence string          // sentence type eg rmc
+	Raw      string          // the sentence as received
+	Time     time.Time       // update time
+	Derived  bool            // computed by the handle eg by TrueWind rather than parsed
+	prefix   string          // prefix of the variable names
+	invalid  map[string]bool // variables marked invalid by MarkInvalid
+}
+
+// Parses a sentence from a named input source eg "gps1" into the data set as Parse does and also
+// into the data set of the source.  Variables are not prefixed so the merged data set holds the
+// latest value from any source, see GetFrom and SourceOf to tell sources apart.
+func (h *Handle) ParseFrom(source string, nmea string) (string, string, error) {
+	return h.parseFrom(source, nmea, "")
+}
+
+func (h *Handle) parseFrom(source, nmea, preFixVar string) (string, string, error) {
+	data, preFix, postFix, err := h.ParseToMap(nmea, preFixVar)
+	if err == nil {
+		h.parsing = &Source{Name: source, Talker: preFix, Sentence: postFix, Raw: nmea, prefix: preFixVar}
+		h.Update(h.applyValidity(postFix, data, preFixVar))
+		h.parsing = nil
+	}
+	return preFix, postFix, err
+}
+
+// records the values of the sentence being parsed in the data set of its source without the
+// prefix, sentences from Parse have no source and so are only merged
+func (h *Handle) recordSourceData(results map[string]string, timeStamp int64) {
+	if h.parsing == nil {
+		return
+	}
+	h.parsing.Time = time.UnixMilli(timeStamp).UTC()
+	if len(h.parsing.Name) == 0 {
+		return
+	}
+	if h.sourceData == nil {
+		h.sourceData = make(map[string]map[string]string)
+	}
+	data, ok := h.sourceData[h.parsing.Name]
+	if !ok {
+		data = make(map[string]string)
+		h.sourceData[h.parsing.Name] = data
+	}
+	for n, v := range results {
+		data[strings.TrimPrefix(n, h.parsing.prefix)] = v
+	}
+}
+
+// records the source of results merged into the data set from the sentence being parsed or
+// given by from, derived variables are marked as such and variables set by Update lose theirs
+func (h *Handle) recordProvenance(results map[string]string, from map[string]Source, timeStamp int64) {
+	if h.sources == nil {
+		h.sources = make(map[string]Source)
+	}
+	for n := range results {
+		if h.derive.merging {
+			h.sources[n] = Source{Time: time.UnixMilli(timeStamp).UTC(), Derived: true}
+		} else if source, ok := from[n]; ok {
+			h.sources[n] = source
+		} else if h.parsing != nil {
+			h.sources[n] = *h.parsing
+		} else {
+			delete(h.sources, n)
+		}
+	}
+}
+
+// Returns the source which last set a variable, Derived if computed by the handle or false if it
+// was set by Update
+func (h *Handle) SourceOf(key string) (Source, bool) {
+	source, ok := h.sources[key]
+	return source, ok
+}
+
+// Returns the latest value of a variable from a source or blank if the source has not given it.
+// key is the name without any prefix given to ParsePrefixVar eg GetFrom("b_", "position").
+func (h *Handle) GetFrom(source string, key string) string {
+	return h.sourceData[source][key]
+}
+
+// Returns a copy of the variables given by a source, named without any prefix
+func (h *Handle) GetMapFrom(source string) map[string]string {
+	data := make(map[string]string, len(h.sourceData[source]))
+	for k, v := range h.sourceData[source] {
+		data[k] = v
+	}
+	return data
+}
+
+// Returns the names of the sources which have given data in order
+func (h *Handle) SourceNames() []string {
+	names := make([]string, 0, len(h.sourceData))
+	for name := range h.sourceData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,73 @@
+package nmea0183
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	gps1 := "$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75"
+	gps2 := "$GNRMC,110911.00,A,5047.4000,N,00054.6000,W,0.10,0.20,150920,0.24,W,A,V*67"
+	if _, _, err := nm.ParseFrom("gps1", gps1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := nm.ParseFrom("gps2", gps2); err != nil {
+		t.Fatal(err)
+	}
+
+	// merged view holds the latest and each source its own
+	if nm.Get("position") != "50° 47.4000'N, 000° 54.6000'W" {
+		t.Errorf("merged position got %s", nm.Get("position"))
+	}
+	if nm.GetFrom("gps1", "position") != "50° 47.3986'N, 000° 54.6007'W" || nm.GetMapFrom("gps2")["sog"] != "0.10" {
+		t.Errorf("source positions got %s %v", nm.GetFrom("gps1", "position"), nm.GetMapFrom("gps2"))
+	}
+	source, ok := nm.SourceOf("position")
+	if !ok || source.Name != "gps2" || source.Talker != "GN" || source.Sentence != "rmc" || source.Raw != gps2 {
+		t.Errorf("source of position got %+v", source)
+	}
+	if names := nm.SourceNames(); len(names) != 2 || names[0] != "gps1" {
+		t.Errorf("source names got %v", names)
+	}
+
+	// a prefix is recorded as the source name
+	nm.ParsePrefixVar(gps1, "b_")
+	if source, _ := nm.SourceOf("b_position"); source.Name != "b_" || nm.GetFrom("b_", "position") != nm.GetFrom("gps1", "position") {
+		t.Errorf("prefixed source got %+v %v", source, nm.GetMapFrom("b_"))
+	}
+
+	// Parse merges without keeping a source data set
+	nm.Parse("$SSDPT,2.8,-0.7")
+	if names := nm.SourceNames(); len(names) != 3 || names[0] != "b_" || nm.GetFrom("", "dbt") != "" {
+		t.Errorf("unnamed source should not be kept got %v", names)
+	}
+
+	// variables set directly have no source
+	nm.Update(map[string]string{"position": "50° 47.0000'N, 000° 54.0000'W"})
+	if _, ok := nm.SourceOf("position"); ok {
+		t.Error("updated position should have no source")
+	}
+}
+
+func TestDerivedSource(t *testing.T) {
+	// values computed by the handle are marked derived and keep that mark when replayed
+	var buf bytes.Buffer
+	nm := DefaultSentences().MakeHandle()
+	nm.SetEncoder(NewEncoder(&buf))
+	nm.AddGridVariable("locator", "position", MaidenheadGrid, 6)
+	nm.Parse("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75")
+	if source, ok := nm.SourceOf("locator"); !ok || !source.Derived || source.Sentence != "" {
+		t.Errorf("locator should be marked derived got %+v", source)
+	}
+	if source, _ := nm.SourceOf("position"); source.Derived || source.Sentence != "rmc" {
+		t.Errorf("position should not be derived got %+v", source)
+	}
+
+	nm2 := DefaultSentences().MakeHandle()
+	nm2.Preferences(0, false)
+	nm2.ReadRecords(&buf)
+	if source, _ := nm2.SourceOf("locator"); !source.Derived {
+		t.Errorf("replayed locator should be derived got %+v", source)
+	}
+}
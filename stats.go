@@ -0,0 +1,244 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statistics of a variable over a window of time.  Angles of circular variables are in degrees
+// with Mean, Min and Max 0 to 360 (or -180 to 180 for signed angles) and StdDev the circular
+// standard deviation.  Rate is the change per second from the first to the last sample.
+type Statistics struct {
+	Count  int
+	Mean   float64
+	Min    float64
+	Max    float64
+	StdDev float64
+	Rate   float64
+}
+
+type statSample struct {
+	ms    int64
+	value float64
+}
+
+type statTracker struct {
+	retain  int64 // milliseconds of samples kept, the largest window
+	windows []int64
+	damping []dampState
+	samples []statSample
+}
+
+type dampState struct {
+	period  int64 // time constant in milliseconds
+	started bool
+	last    int64
+	x, y    float64 // value or for circular variables the unit vector
+}
+
+// Sets whether a variable is an angle which needs circular statistics and damping.  By default
+// variables with a compass or angle format eg x.x,T, x.x° or x.x°,l and their _smooth variables
+// are circular and all others are linear.
+func (h *Handle) SetCircular(key string, circular bool) {
+	if h.circular == nil {
+		h.circular = make(map[string]bool)
+	}
+	h.circular[key] = circular
+}
+
+func (h *Handle) isCircular(key string) bool {
+	if circular, ok := h.circular[key]; ok {
+		return circular
+	}
+	tType, _ := getConversion(h.variableFormat(strings.TrimSuffix(key, "_smooth")))
+	return tType == "compass" || tType == "angle" || tType == "signed angle"
+}
+
+// Keeps statistics of a numeric variable over a window as it is updated in variables named
+// key.avg, key.min, key.max, key.std and key.rate followed by the window eg hdm.avg10s.
+// Statistics also gives them for any window up to the largest added.
+func (h *Handle) AddStatistics(key string, window time.Duration) error {
+	if window <= 0 {
+		return fmt.Errorf("statistics window must be positive")
+	}
+	t := h.tracker(key)
+	t.windows = append(t.windows, window.Milliseconds())
+	t.retain = max(t.retain, window.Milliseconds())
+	return nil
+}
+
+// Keeps an exponentially damped copy of a numeric variable with time constant period in a
+// variable named key.damp followed by the period eg hdm.damp5s
+func (h *Handle) AddDamping(key string, period time.Duration) error {
+	if period <= 0 {
+		return fmt.Errorf("damping period must be positive")
+	}
+	t := h.tracker(key)
+	t.damping = append(t.damping, dampState{period: period.Milliseconds()})
+	return nil
+}
+
+func (h *Handle) tracker(key string) *statTracker {
+	if h.trackers == nil {
+		h.trackers = make(map[string]*statTracker)
+		h.OnUpdate(h.updateTrackers)
+	}
+	if t, ok := h.trackers[key]; ok {
+		return t
+	}
+	t := &statTracker{}
+	h.trackers[key] = t
+	return t
+}
+
+func (h *Handle) updateTrackers(results map[string]string) {
+	for key, t := range h.trackers {
+		if _, ok := results[key]; !ok {
+			continue
+		}
+		value, err := h.Float(key)
+		if err != nil {
+			continue
+		}
+		timeNow := h.history[key]
+		circular := h.isCircular(key)
+		values := map[string]string{}
+
+		for i := range t.damping {
+			d := &t.damping[i]
+			x, y := value, 0.0
+			if circular {
+				x, y = math.Cos(toRad(value)), math.Sin(toRad(value))
+			}
+			alpha := 1.0
+			if d.started {
+				alpha = 1 - math.Exp(-float64(timeNow-d.last)/float64(d.period))
+			}
+			d.x += alpha * (x - d.x)
+			d.y += alpha * (y - d.y)
+			d.started, d.last = true, timeNow
+			damped := d.x
+			if circular {
+				damped = h.angle(key, toDeg(math.Atan2(d.y, d.x)))
+			}
+			values[key+".damp"+windowName(d.period)] = strconv.FormatFloat(damped, 'f', 2, 64)
+		}
+
+		if t.retain > 0 {
+			samples := []statSample{}
+			for _, s := range t.samples {
+				if timeNow-s.ms < t.retain {
+					samples = append(samples, s)
+				}
+			}
+			t.samples = append(samples, statSample{ms: timeNow, value: value})
+			for _, window := range t.windows {
+				stats, _ := h.statistics(key, t, window)
+				name := windowName(window)
+				values[key+".avg"+name] = strconv.FormatFloat(stats.Mean, 'f', 2, 64)
+				values[key+".min"+name] = strconv.FormatFloat(stats.Min, 'f', 2, 64)
+				values[key+".max"+name] = strconv.FormatFloat(stats.Max, 'f', 2, 64)
+				values[key+".std"+name] = strconv.FormatFloat(stats.StdDev, 'f', 2, 64)
+				values[key+".rate"+name] = strconv.FormatFloat(stats.Rate, 'f', 3, 64)
+			}
+		}
+		h.setVars(values)
+	}
+}
+
+// Returns statistics of a variable over a window up to the largest given to AddStatistics
+func (h *Handle) Statistics(key string, window time.Duration) (Statistics, error) {
+	t, ok := h.trackers[key]
+	if !ok || window.Milliseconds() > t.retain {
+		return Statistics{}, fmt.Errorf("statistics of %s over %s are not kept", key, window)
+	}
+	return h.statistics(key, t, window.Milliseconds())
+}
+
+func (h *Handle) statistics(key string, t *statTracker, window int64) (Statistics, error) {
+	var samples []statSample
+	if l := len(t.samples); l > 0 {
+		for _, s := range t.samples {
+			if t.samples[l-1].ms-s.ms < window {
+				samples = append(samples, s)
+			}
+		}
+	}
+	n := len(samples)
+	if n == 0 {
+		return Statistics{}, fmt.Errorf("no samples of %s", key)
+	}
+	stats := Statistics{Count: n}
+	first, last := samples[0], samples[n-1]
+
+	if h.isCircular(key) {
+		var sumSin, sumCos, change float64
+		for i, s := range samples {
+			sumSin += math.Sin(toRad(s.value))
+			sumCos += math.Cos(toRad(s.value))
+			if i > 0 {
+				change += wrap180(s.value - samples[i-1].value)
+			}
+		}
+		mean := toDeg(math.Atan2(sumSin, sumCos))
+		minOffset, maxOffset := 0.0, 0.0
+		for i, s := range samples {
+			offset := wrap180(s.value - mean)
+			if i == 0 || offset < minOffset {
+				minOffset = offset
+			}
+			if i == 0 || offset > maxOffset {
+				maxOffset = offset
+			}
+		}
+		r := math.Min(math.Hypot(sumSin, sumCos)/float64(n), 1)
+		stats.Mean = h.angle(key, mean)
+		stats.Min = h.angle(key, mean+minOffset)
+		stats.Max = h.angle(key, mean+maxOffset)
+		stats.StdDev = toDeg(math.Sqrt(-2 * math.Log(r)))
+		if last.ms > first.ms {
+			stats.Rate = change / (float64(last.ms-first.ms) / 1000)
+		}
+		return stats, nil
+	}
+
+	var sum, sumSq float64
+	stats.Min, stats.Max = first.value, first.value
+	for _, s := range samples {
+		sum += s.value
+		stats.Min = math.Min(stats.Min, s.value)
+		stats.Max = math.Max(stats.Max, s.value)
+	}
+	stats.Mean = sum / float64(n)
+	for _, s := range samples {
+		sumSq += (s.value - stats.Mean) * (s.value - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(sumSq / float64(n))
+	if last.ms > first.ms {
+		stats.Rate = (last.value - first.value) / (float64(last.ms-first.ms) / 1000)
+	}
+	return stats, nil
+}
+
+// Returns an angle 0 to 360 or -180 to 180 for variables holding signed angles eg twa
+func (h *Handle) angle(key string, deg float64) float64 {
+	tType, _ := getConversion(h.variableFormat(strings.TrimSuffix(key, "_smooth")))
+	if tType == "signed angle" {
+		return wrap180(deg)
+	}
+	return wrap360(deg)
+}
+
+// Returns a window or period as used in variable names eg 10s, 5m or 500ms
+func windowName(ms int64) string {
+	switch {
+	case ms%60000 == 0:
+		return strconv.FormatInt(ms/60000, 10) + "m"
+	case ms%1000 == 0:
+		return strconv.FormatInt(ms/1000, 10) + "s"
+	}
+	return strconv.FormatInt(ms, 10) + "ms"
+}
@@ -0,0 +1,110 @@
+package nmea0183
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func feedStats(h *Handle, key string, values []string) {
+	start := time.Date(2020, 9, 15, 11, 0, 0, 0, time.UTC)
+	for i, v := range values {
+		h.Update(map[string]string{"datetime": dateTimeStr(start.Add(time.Duration(i) * time.Second)), key: v})
+	}
+}
+
+func TestCircularStatistics(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AddStatistics("hdm", 10*time.Second)
+	nm.AddDamping("hdm", 5*time.Second)
+	feedStats(nm, "hdm", []string{"359.0°M", "1.0°M", "359.0°M", "1.0°M"})
+
+	if avg, err := nm.Float("hdm.avg10s"); err != nil || math.Abs(wrap180(avg)) > 0.01 {
+		t.Errorf("average of 359 and 1 got %f %v", avg, err)
+	}
+	if damp, err := nm.Float("hdm.damp5s"); err != nil || math.Abs(wrap180(damp)) > 1 {
+		t.Errorf("damped 359 and 1 got %f %v", damp, err)
+	}
+	stats, err := nm.Statistics("hdm", 10*time.Second)
+	if err != nil || stats.Count != 4 || stats.Min != 359 || stats.Max != 1 {
+		t.Errorf("statistics got %+v %v", stats, err)
+	}
+	if math.Abs(stats.StdDev-1) > 0.01 {
+		t.Errorf("circular std dev got %f", stats.StdDev)
+	}
+	if _, err := nm.Statistics("hdm", time.Minute); err == nil {
+		t.Error("statistics over a window not kept should fail")
+	}
+}
+
+func TestSignedAngleStatistics(t *testing.T) {
+	// twa is circular by default and signed so its mean either side of dead astern is near 180
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AddStatistics("twa", 10*time.Second)
+	nm.AddStatistics("tmg_smooth", 10*time.Second)
+	feedStats(nm, "twa", []string{"170.0", "-170.0", "170.0", "-170.0"})
+	stats, err := nm.Statistics("twa", 10*time.Second)
+	if err != nil || math.Abs(math.Abs(stats.Mean)-180) > 0.01 || stats.Min != 170 || stats.Max != -170 {
+		t.Errorf("twa statistics got %+v %v", stats, err)
+	}
+	if avg, err := nm.Float("twa.avg10s"); err != nil || avg < -180 || avg > 180 {
+		t.Errorf("twa.avg10s should be signed got %f %v", avg, err)
+	}
+	feedStats(nm, "tmg_smooth", []string{"358.0", "2.0"})
+	if stats, _ := nm.Statistics("tmg_smooth", 10*time.Second); math.Abs(wrap180(stats.Mean)) > 0.01 {
+		t.Errorf("tmg_smooth mean of 358 and 2 got %f", stats.Mean)
+	}
+}
+
+func TestCircularFromFormat(t *testing.T) {
+	// angles are found from their format so variables added by the user or parsed with a prefix
+	// are circular too
+	variables := GetDefaultVars()
+	variables["course"] = "x.x°"
+	nm := MakeSentences(GetDefaultFormats(), variables).MakeHandle()
+	nm.Preferences(0, false)
+	nm.AddStatistics("course", 10*time.Second)
+	nm.AddStatistics("b_tmg", 10*time.Second)
+	feedStats(nm, "course", []string{"358.0", "2.0"})
+	if stats, _ := nm.Statistics("course", 10*time.Second); math.Abs(wrap180(stats.Mean)) > 0.01 {
+		t.Errorf("course mean of 358 and 2 got %f", stats.Mean)
+	}
+	nm.ParsePrefixVar("$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,358.0,150920,0.24,W,D,V", "b_")
+	nm.ParsePrefixVar("$GPRMC,110911.59,A,5047.3986,N,00054.6007,W,0.08,2.0,150920,0.24,W,D,V", "b_")
+	if stats, err := nm.Statistics("b_tmg", 10*time.Second); err != nil || stats.Count != 2 || math.Abs(wrap180(stats.Mean)) > 0.01 {
+		t.Errorf("b_tmg mean of 358 and 2 got %+v %v", stats, err)
+	}
+	nm.SetCircular("course", false)
+	if !nm.isCircular("tmg") || nm.isCircular("course") || nm.isCircular("sog") {
+		t.Error("SetCircular should override the format")
+	}
+}
+
+func TestLinearStatistics(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.AddStatistics("dbt", 3*time.Second)
+	feedStats(nm, "dbt", []string{"10.0", "11.0", "12.0", "13.0", "14.0"})
+
+	// only the last 3 seconds are kept
+	stats, err := nm.Statistics("dbt", 3*time.Second)
+	if err != nil || stats.Count != 3 || stats.Mean != 13 || stats.Min != 12 || stats.Max != 14 {
+		t.Errorf("statistics got %+v %v", stats, err)
+	}
+	if math.Abs(stats.Rate-1) > 1e-9 {
+		t.Errorf("rate got %f", stats.Rate)
+	}
+	if nm.Get("dbt.rate3s") != "1.000" {
+		t.Errorf("dbt.rate3s got %s", nm.Get("dbt.rate3s"))
+	}
+}
+
+func TestWindowName(t *testing.T) {
+	for ms, want := range map[int64]string{10000: "10s", 300000: "5m", 500: "500ms"} {
+		if got := windowName(ms); got != want {
+			t.Errorf("window %d got %s want %s", ms, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package nmea0183
+
+// A rule saying when the variables of a sentence are valid eg RMC position, sog and tmg only when
+// status is A.  Variables are invalid when field has a value not in Valid, if Valid is given, or
+// a value in Invalid.  A blank field does not gate the variables.
+type ValidityRule struct {
+	Field   string   `mapstructure:"field"`
+	Valid   []string `mapstructure:"valid"`
+	Invalid []string `mapstructure:"invalid"`
+	Keys    []string `mapstructure:"keys"` // variables gated, none for all but field
+}
+
+// What is done with variables from a sentence its validity rule says are invalid
+type InvalidAction int
+
+const (
+	AcceptInvalid InvalidAction = iota // stored as usual, validity rules are not applied
+	DropInvalid                        // the data set keeps the last valid value
+	StoreInvalid                       // stored with the prefix invalid_ eg invalid_position
+	MarkInvalid                        // stored as usual but Valid returns false
+)
+
+const invalidPrefix = "invalid_"
+
+// Validity rules defined by default, applied once ValidityPreferences is given an action other
+// than AcceptInvalid
+func GetDefaultValidity() map[string]ValidityRule {
+	return map[string]ValidityRule{
+		"rmc": {Field: "status", Valid: []string{"A"}, Keys: []string{"position", "sog", "tmg"}},
+		"gll": {Field: "status", Valid: []string{"A"}, Keys: []string{"position"}},
+		"apa": {Field: "ap_status", Valid: []string{"A"}, Keys: []string{"xte", "bearing_to_waypt"}},
+		"apb": {Field: "ap_status", Valid: []string{"A"}, Keys: []string{"xte", "bearing_origin_to_waypt", "bearing_position_to_waypt", "hts"}},
+		"xte": {Field: "ap_status", Valid: []string{"A"}, Keys: []string{"xte"}},
+		"rmb": {Field: "ap_status", Valid: []string{"A"}, Keys: []string{"rmb_xte", "dtw", "btw", "vmg"}},
+		"mwv": {Field: "wind_status", Valid: []string{"A"}, Keys: []string{"wind_angle", "wind_speed"}},
+	}
+}
+
+// Sets what is done with variables a validity rule says are invalid.  The default is AcceptInvalid
+// which stores them as before validity rules were added.
+func (h *Handle) ValidityPreferences(action InvalidAction) {
+	h.settings.invalidAction = action
+}
+
+// Returns false if the last value of a variable came from a sentence flagged invalid and was
+// stored by MarkInvalid, setting the variable any other way makes it valid
+func (h *Handle) Valid(key string) bool {
+	return !h.invalid[key]
+}
+
+// Applies the validity rule of a sentence type to its parsed results, variable names prefixed
+// with prefix, and returns the results to update the data set with
+func (h *Handle) applyValidity(sentenceType string, results map[string]string, prefix string) map[string]string {
+	rule, ok := h.sentences.validity[sentenceType]
+	if !ok || h.settings.invalidAction == AcceptInvalid {
+		return results
+	}
+	// a blank flag says nothing about validity
+	flag := results[prefix+rule.Field]
+	if len(flag) == 0 {
+		return results
+	}
+	valid := len(rule.Valid) == 0
+	for _, v := range rule.Valid {
+		valid = valid || v == flag
+	}
+	for _, v := range rule.Invalid {
+		valid = valid && v != flag
+	}
+
+	gated := make(map[string]bool)
+	if len(rule.Keys) == 0 {
+		for n := range results {
+			gated[n] = n != prefix+rule.Field
+		}
+	}
+	for _, k := range rule.Keys {
+		gated[prefix+k] = true
+	}
+
+	checked := make(map[string]string, len(results))
+	for n, v := range results {
+		if !gated[n] {
+			checked[n] = v
+			continue
+		}
+		if valid {
+			checked[n] = v
+			continue
+		}
+		switch h.settings.invalidAction {
+		case StoreInvalid:
+			checked[invalidPrefix+n] = v
+		case MarkInvalid:
+			checked[n] = v
+			if h.parsing != nil {
+				if h.parsing.invalid == nil {
+					h.parsing.invalid = make(map[string]bool)
+				}
+				h.parsing.invalid[n] = true
+			}
+		}
+	}
+	return checked
+}
+
+// marks a variable being merged invalid if the sentence being parsed marked it, otherwise valid
+func (h *Handle) markValidity(key string) {
+	if h.parsing != nil && !h.derive.merging && h.parsing.invalid[key] {
+		if h.invalid == nil {
+			h.invalid = make(map[string]bool)
+		}
+		h.invalid[key] = true
+		return
+	}
+	delete(h.invalid, key)
+}
@@ -0,0 +1,73 @@
+package nmea0183
+
+import (
+	"testing"
+)
+
+func TestValidityActions(t *testing.T) {
+	good := "$GPRMC,110910.59,A,5047.3986,N,00054.6007,W,0.08,0.19,150920,0.24,W,D,V*75"
+	bad := rmcAt("GP", 11, "V", 1)
+
+	// by default validity rules are not applied
+	nm := DefaultSentences().MakeHandle()
+	nm.Parse(good)
+	nm.Parse(bad)
+	if nm.Get("position") != "50° 01.0000'N, 001° 00.0000'W" || !nm.Valid("position") {
+		t.Errorf("accept got position %s valid %v", nm.Get("position"), nm.Valid("position"))
+	}
+
+	nm = DefaultSentences().MakeHandle()
+	nm.ValidityPreferences(DropInvalid)
+	nm.Parse(good)
+	nm.Parse(bad)
+	if nm.Get("position") != "50° 47.3986'N, 000° 54.6007'W" || nm.Get("status") != "V" || nm.Get("sog") != "0.08" {
+		t.Errorf("drop kept position %s status %s sog %s", nm.Get("position"), nm.Get("status"), nm.Get("sog"))
+	}
+
+	nm = DefaultSentences().MakeHandle()
+	nm.ValidityPreferences(StoreInvalid)
+	nm.Parse(good)
+	nm.Parse(bad)
+	if nm.Get("position") != "50° 47.3986'N, 000° 54.6007'W" || nm.Get("invalid_position") != "50° 01.0000'N, 001° 00.0000'W" {
+		t.Errorf("store got position %s invalid_position %s", nm.Get("position"), nm.Get("invalid_position"))
+	}
+
+	nm = DefaultSentences().MakeHandle()
+	nm.ValidityPreferences(MarkInvalid)
+	nm.Parse(bad)
+	if nm.Get("position") != "50° 01.0000'N, 001° 00.0000'W" || nm.Valid("position") || !nm.Valid("fix_date") {
+		t.Errorf("mark got position %s valid %v", nm.Get("position"), nm.Valid("position"))
+	}
+	nm.Parse(good)
+	if !nm.Valid("position") {
+		t.Error("a valid position should clear the mark")
+	}
+	// as does setting the variable by Update or without a rule
+	nm.Parse(bad)
+	nm.Update(map[string]string{"position": "50° 02.0000'N, 001° 00.0000'W"})
+	if !nm.Valid("position") {
+		t.Error("an updated position should clear the mark")
+	}
+}
+
+func TestValidityRules(t *testing.T) {
+	sentences := DefaultSentences()
+	sentences.AddFormat("gga", []string{"fix_time", "position", "fix_quality"})
+	sentences.AddVariable("fix_quality", "x")
+	sentences.AddValidity("gga", ValidityRule{Field: "fix_quality", Invalid: []string{"0"}})
+	nm := sentences.MakeHandle()
+	nm.ValidityPreferences(DropInvalid)
+	nm.ParsePrefixVar("$GPGGA,110910.59,5047.3986,N,00054.6007,W,1", "a_")
+	nm.ParsePrefixVar("$GPGGA,110911.59,5100.0000,N,00054.6007,W,0", "a_")
+	if nm.Get("a_position") != "50° 47.3986'N, 000° 54.6007'W" || nm.Get("a_fix_time") != "11:09:10.59" {
+		t.Errorf("gga with fix quality 0 got position %s time %s", nm.Get("a_position"), nm.Get("a_fix_time"))
+	}
+
+	loaded := DefaultSentences()
+	if err := loaded.Load("./example"); err != nil {
+		t.Fatal(err)
+	}
+	if rule := loaded.validity["rmc"]; rule.Field != "status" || len(rule.Valid) != 1 || rule.Valid[0] != "A" || len(rule.Keys) != 3 {
+		t.Errorf("loaded rmc rule got %+v", rule)
+	}
+}
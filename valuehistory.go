@@ -0,0 +1,252 @@
+package nmea0183
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on the values kept for a variable by KeepHistory.  The oldest values are dropped once
+// any limit is reached, a limit of 0 is not applied.
+type HistoryLimits struct {
+	Count int           // number of values
+	Age   time.Duration // age of the oldest value relative to the newest
+	Bytes int           // approximate memory used
+}
+
+// A value of a variable in its internal string format and the time it was set
+type TimedValue struct {
+	Time  time.Time
+	Value string
+}
+
+// bytes counted for each value in addition to its length
+const timedValueOverhead = 32
+
+type valueLog struct {
+	limits HistoryLimits
+	ms     []int64
+	values []string
+	start  int // index of the oldest value kept, entries before are dropped
+	bytes  int
+}
+
+// Keeps a history of the values of keys, or of every variable if no keys are given, within limits.
+// The history can be queried with Values, EachValue, ValueAt, FloatAt and PositionAt.
+func (h *Handle) KeepHistory(limits HistoryLimits, keys ...string) {
+	if h.valueLogs == nil {
+		h.valueLogs = make(map[string]*valueLog)
+	}
+	if len(keys) == 0 {
+		h.keepAll = &limits
+	}
+	for _, key := range keys {
+		if l, ok := h.valueLogs[key]; ok {
+			l.limits = limits
+			l.trim()
+		} else {
+			h.valueLogs[key] = &valueLog{limits: limits}
+		}
+	}
+}
+
+// Stops keeping the history of keys or of every variable if no keys are given
+func (h *Handle) ForgetHistory(keys ...string) {
+	if len(keys) == 0 {
+		h.valueLogs, h.keepAll = nil, nil
+	}
+	for _, key := range keys {
+		delete(h.valueLogs, key)
+	}
+}
+
+func (h *Handle) logValue(key, value string, ms int64) {
+	l, ok := h.valueLogs[key]
+	if !ok {
+		if h.keepAll == nil {
+			return
+		}
+		l = &valueLog{limits: *h.keepAll}
+		h.valueLogs[key] = l
+	}
+	l.add(value, ms)
+}
+
+func (l *valueLog) add(value string, ms int64) {
+	// values normally arrive in time order but keep them sorted if not
+	i := len(l.ms)
+	if i > l.start && ms < l.ms[i-1] {
+		i = l.start + sort.Search(len(l.ms)-l.start, func(j int) bool { return l.ms[l.start+j] > ms })
+	}
+	l.ms = append(l.ms, 0)
+	l.values = append(l.values, "")
+	copy(l.ms[i+1:], l.ms[i:])
+	copy(l.values[i+1:], l.values[i:])
+	l.ms[i], l.values[i] = ms, value
+	l.bytes += len(value) + timedValueOverhead
+	l.trim()
+}
+
+func (l *valueLog) trim() {
+	end := len(l.ms)
+	for l.start < end {
+		count := end - l.start
+		if (l.limits.Count > 0 && count > l.limits.Count) ||
+			(l.limits.Age > 0 && l.ms[end-1]-l.ms[l.start] > l.limits.Age.Milliseconds()) ||
+			(l.limits.Bytes > 0 && l.bytes > l.limits.Bytes && count > 1) {
+			l.bytes -= len(l.values[l.start]) + timedValueOverhead
+			l.values[l.start] = ""
+			l.start++
+			continue
+		}
+		break
+	}
+	// reuse the space of dropped values once it is half the buffer
+	if l.start > 0 && l.start >= end/2 {
+		l.ms = append(l.ms[:0], l.ms[l.start:]...)
+		l.values = append(l.values[:0], l.values[l.start:]...)
+		l.start = 0
+	}
+}
+
+// Returns the values of a variable set from time from up to and including time to, oldest first
+func (h *Handle) Values(key string, from, to time.Time) []TimedValue {
+	var values []TimedValue
+	h.EachValue(key, from, to, func(v TimedValue) bool {
+		values = append(values, v)
+		return true
+	})
+	return values
+}
+
+// Calls f with each value of a variable set from time from up to and including time to, oldest
+// first, until f returns false
+func (h *Handle) EachValue(key string, from, to time.Time, f func(TimedValue) bool) {
+	l, ok := h.valueLogs[key]
+	if !ok {
+		return
+	}
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+	i := l.start + sort.Search(len(l.ms)-l.start, func(j int) bool { return l.ms[l.start+j] >= fromMs })
+	for ; i < len(l.ms) && l.ms[i] <= toMs; i++ {
+		if !f(TimedValue{Time: time.UnixMilli(l.ms[i]).UTC(), Value: l.values[i]}) {
+			return
+		}
+	}
+}
+
+// returns the values either side of at, which are the same value if one was set at that time or
+// the value after is blank so values are not interpolated towards a blank or cleared variable
+func (h *Handle) valuesAround(key string, at time.Time) (TimedValue, TimedValue, error) {
+	l, ok := h.valueLogs[key]
+	if !ok || len(l.ms) == l.start {
+		return TimedValue{}, TimedValue{}, fmt.Errorf("no history of %s", key)
+	}
+	ms := at.UnixMilli()
+	if ms < l.ms[l.start] || ms > l.ms[len(l.ms)-1] {
+		return TimedValue{}, TimedValue{}, fmt.Errorf("%s is outside the history of %s", at.Format(time.RFC3339), key)
+	}
+	// the first value after ms, at least one is at or before ms
+	i := l.start + sort.Search(len(l.ms)-l.start, func(j int) bool { return l.ms[l.start+j] > ms })
+	before := TimedValue{Time: time.UnixMilli(l.ms[i-1]).UTC(), Value: l.values[i-1]}
+	if l.ms[i-1] == ms || i == len(l.ms) || len(l.values[i]) == 0 {
+		return before, before, nil
+	}
+	return before, TimedValue{Time: time.UnixMilli(l.ms[i]).UTC(), Value: l.values[i]}, nil
+}
+
+// returns the fraction of the time from before to after at is
+func fractionAt(before, after TimedValue, at time.Time) float64 {
+	span := after.Time.Sub(before.Time)
+	if span <= 0 {
+		return 0
+	}
+	return float64(at.Sub(before.Time)) / float64(span)
+}
+
+// Returns the numeric value of a variable at a time interpolated between the values either side.
+// Circular variables, see SetCircular, are interpolated the shortest way round.
+func (h *Handle) FloatAt(key string, at time.Time) (float64, error) {
+	before, after, err := h.valuesAround(key, at)
+	if err != nil {
+		return 0, err
+	}
+	template := h.variableFormat(key)
+	b, okB := floatValue(typedValue(template, before.Value))
+	a, okA := floatValue(typedValue(template, after.Value))
+	if !okB || !okA {
+		return 0, fmt.Errorf("%s is not numeric", key)
+	}
+	fraction := fractionAt(before, after, at)
+	if h.isCircular(key) {
+		return h.angle(key, b+fraction*wrap180(a-b)), nil
+	}
+	return b + fraction*(a-b), nil
+}
+
+// Returns the position held by a variable at a time interpolated between the positions either side
+// eg lat, long, err := handle.PositionAt("position", time.Date(2020, 9, 15, 14, 32, 10, 0, time.UTC))
+func (h *Handle) PositionAt(key string, at time.Time) (float64, float64, error) {
+	before, after, err := h.valuesAround(key, at)
+	if err != nil {
+		return 0, 0, err
+	}
+	latB, longB, errB := LatLongToFloat(before.Value)
+	latA, longA, errA := LatLongToFloat(after.Value)
+	if errB != nil || errA != nil {
+		return 0, 0, fmt.Errorf("%s is not a position", key)
+	}
+	fraction := fractionAt(before, after, at)
+	return latB + fraction*(latA-latB), wrap180(longB + fraction*wrap180(longA-longB)), nil
+}
+
+// Returns the value of a variable at a time in its internal string format.  Positions, compass
+// and plain numeric values are interpolated between the values either side, other values are
+// the last value set at or before the time.
+func (h *Handle) ValueAt(key string, at time.Time) (string, error) {
+	before, after, err := h.valuesAround(key, at)
+	if err != nil {
+		return "", err
+	}
+	if before.Time.Equal(after.Time) {
+		return before.Value, nil
+	}
+	tType, _ := getConversion(h.variableFormat(key))
+	if tType == "" {
+		// derived variables have no format definition
+		if _, _, err := LatLongToFloat(before.Value); err == nil {
+			tType = "position"
+		}
+	}
+	switch tType {
+	case "position":
+		lat, long, err := h.PositionAt(key, at)
+		if err != nil {
+			return "", err
+		}
+		latStr, longStr, err := LatLongToStringFormat(lat, long, DegreesDecimalMinutes, minuteDecimals(before.Value))
+		return latStr + ", " + longStr, err
+	case "compass":
+		f, err := h.FloatAt(key, at)
+		if err != nil {
+			return "", err
+		}
+		return compassStr(f, before.Value[len(before.Value)-1:]), nil
+	case "", "float", "integer", "angle", "signed angle":
+		if _, err := strconv.ParseFloat(before.Value, 64); err != nil {
+			break
+		}
+		f, err := h.FloatAt(key, at)
+		if err != nil {
+			return "", err
+		}
+		decimals := 0
+		if _, fraction, found := strings.Cut(before.Value, "."); found {
+			decimals = len(fraction)
+		}
+		return strconv.FormatFloat(f, 'f', decimals, 64), nil
+	}
+	return before.Value, nil
+}
@@ -0,0 +1,108 @@
+package nmea0183
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValueHistory(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.KeepHistory(HistoryLimits{Count: 3}, "position", "hdm", "dbt")
+	start := time.Date(2020, 9, 15, 14, 32, 0, 0, time.UTC)
+	for i, v := range []struct{ position, hdm, dbt string }{
+		{"50° 00.0000'N, 001° 00.0000'W", "350.0°M", "10.0"},
+		{"50° 00.0000'N, 001° 00.0000'W", "358.0°M", "10.0"},
+		{"50° 01.0000'N, 001° 02.0000'W", "4.0°M", "12.0"},
+		{"50° 02.0000'N, 001° 04.0000'W", "6.0°M", "14.5"},
+	} {
+		nm.Update(map[string]string{"datetime": dateTimeStr(start.Add(time.Duration(i) * 20 * time.Second)),
+			"position": v.position, "hdm": v.hdm, "dbt": v.dbt})
+	}
+
+	// only the last 3 values are kept
+	values := nm.Values("hdm", start, start.Add(time.Hour))
+	if len(values) != 3 || values[0].Value != "358.0°M" || !values[2].Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("values got %v", values)
+	}
+	if _, err := nm.ValueAt("hdm", start); err == nil {
+		t.Error("value before the history should fail")
+	}
+
+	at := start.Add(30 * time.Second)
+	lat, long, err := nm.PositionAt("position", at)
+	if err != nil || math.Abs(lat-(50+0.5/60)) > 1e-9 || math.Abs(long-(-1-1.0/60)) > 1e-9 {
+		t.Errorf("position at got %f %f %v", lat, long, err)
+	}
+	if pos, _ := nm.ValueAt("position", at); pos != "50° 00.5000'N, 001° 01.0000'W" {
+		t.Errorf("position value at got %s", pos)
+	}
+	// the heading goes the short way through north
+	if hdm, _ := nm.ValueAt("hdm", start.Add(25*time.Second)); hdm != "359.5°M" {
+		t.Errorf("hdm at got %s", hdm)
+	}
+	if dbt, _ := nm.ValueAt("dbt", start.Add(55*time.Second)); dbt != "13.9" {
+		t.Errorf("dbt at got %s", dbt)
+	}
+	if dbt, _ := nm.ValueAt("dbt", start.Add(40*time.Second)); dbt != "12.0" {
+		t.Errorf("dbt at a value got %s", dbt)
+	}
+}
+
+func TestPrefixedValueHistory(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.KeepHistory(HistoryLimits{Count: 3}, "b_hdm")
+	start := time.Date(2020, 9, 15, 14, 32, 0, 0, time.UTC)
+	for i, hdm := range []string{"$HCHDM,358.0,M", "$HCHDM,4.0,M"} {
+		nm.Update(map[string]string{"datetime": dateTimeStr(start.Add(time.Duration(i) * 20 * time.Second))})
+		nm.ParsePrefixVar(hdm, "b_")
+	}
+
+	// b_hdm is a compass variable so goes the short way through north
+	at := start.Add(10 * time.Second)
+	if hdm, err := nm.FloatAt("b_hdm", at); err != nil || math.Abs(hdm-1) > 1e-9 {
+		t.Errorf("b_hdm float at got %f %v", hdm, err)
+	}
+	if hdm, _ := nm.ValueAt("b_hdm", at); hdm != "1.0°M" {
+		t.Errorf("b_hdm at got %s", hdm)
+	}
+}
+
+func TestValueHistoryLimits(t *testing.T) {
+	l := &valueLog{limits: HistoryLimits{Age: 10 * time.Second}}
+	for i := int64(0); i < 100; i++ {
+		l.add("1", i*1000)
+	}
+	if n := len(l.ms) - l.start; n != 11 || l.ms[l.start] != 89000 {
+		t.Errorf("age limit kept %d from %d", n, l.ms[l.start])
+	}
+	l = &valueLog{limits: HistoryLimits{Bytes: 10 * (timedValueOverhead + 1)}}
+	for i := int64(0); i < 100; i++ {
+		l.add("1", i*1000)
+	}
+	if n := len(l.ms) - l.start; n != 10 || l.bytes != 10*(timedValueOverhead+1) {
+		t.Errorf("byte limit kept %d using %d", n, l.bytes)
+	}
+	// a value out of order is kept in time order
+	l.add("2", 95500)
+	if l.values[len(l.values)-5] != "2" {
+		t.Errorf("out of order value got %v", l.values[l.start:])
+	}
+}
+
+func TestKeepAllHistory(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.KeepHistory(HistoryLimits{Count: 10})
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:09:10.59+00:00", "dbt": "10.0", "sog": "5.0"})
+	if len(nm.Values("sog", time.Time{}, time.Now())) != 1 {
+		t.Error("all variables should be kept")
+	}
+	nm.ForgetHistory()
+	nm.Update(map[string]string{"dbt": "11.0"})
+	if len(nm.Values("dbt", time.Time{}, time.Now())) != 0 {
+		t.Error("history should be forgotten")
+	}
+}
@@ -0,0 +1,136 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Returns the value of a variable in data converted to a go type chosen by the format
+// type of the variable definition, or nil if the variable is not present or is blank.
+// See typedValue for the types returned.
+func (h *Handle) TypedValue(key string) interface{} {
+	val, ok := h.data[key]
+	if !ok {
+		return nil
+	}
+	return typedValue(h.sentences.variables[key], val)
+}
+
+// Converts an internal format string to a go type according to the format template:
+//
+//	float, integer, deviation, offset float64 or int64
+//	lat, long                         float64 decimal degrees, minus for South and West
+//	position                          map with "lat" and "long" as float64
+//	compass                           map with "value" float64 and "ref" eg T or M
+//	cross track error                 map with "value" float64, "steer" L or R and "units"
+//	datetime                          time.Time
+//	day, month, year                  int64
+//
+// all other types and values which cannot be converted are returned as the string
+func typedValue(template, value string) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	tType, _ := getConversion(template)
+	switch tType {
+	case "float", "signed float", "deviation", "offset", "distance", "speed", "angle", "signed angle":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "integer", "signed integer", "day", "month", "year", "plan_day", "plan_month", "plan_year":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case "lat":
+		if lat, err := coordToFloat(value, 'N', 'S'); err == nil {
+			return lat
+		}
+	case "long":
+		if long, err := coordToFloat(value, 'E', 'W'); err == nil {
+			return long
+		}
+	case "position":
+		if lat, long, err := LatLongToFloat(value); err == nil {
+			return map[string]interface{}{"lat": lat, "long": long}
+		}
+	case "compass":
+		if split := strings.SplitN(value, "°", 2); len(split) == 2 {
+			if f, err := strconv.ParseFloat(split[0], 64); err == nil {
+				return map[string]interface{}{"value": f, "ref": split[1]}
+			}
+		}
+	case "cross track error":
+		l := len(value)
+		if l > 2 {
+			if f, err := strconv.ParseFloat(value[1:l-1], 64); err == nil {
+				return map[string]interface{}{"value": f, "steer": value[:1], "units": value[l-1:]}
+			}
+		}
+	case "datetime", "plan_datetime":
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return value
+}
+
+// Returns a numeric variable in data as a float64.  Compass and cross track error variables
+// give their value with cross track error negative when the steer direction is L.
+// Lat and long give decimal degrees with minus values for South and West.
+// Returns an error if the variable is not present, blank or not numeric eg a position
+func (h *Handle) Float(key string) (float64, error) {
+	val, ok := h.data[key]
+	if !ok || len(val) == 0 {
+		return 0, fmt.Errorf("no value for %s", key)
+	}
+	if f, ok := floatValue(typedValue(h.sentences.variables[key], val)); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("%s is not numeric", key)
+}
+
+// Returns a typed value as a float64 if it has a single numeric value
+func floatValue(typed interface{}) (float64, bool) {
+	switch v := typed.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case string:
+		// derived variables have no format definition
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, true
+		}
+	case map[string]interface{}:
+		if f, ok := v["value"].(float64); ok {
+			if v["steer"] == "L" {
+				f = -f
+			}
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// Returns a bearing in degrees in the internal compass format eg 236.6°T, ref is T or M
+func compassStr(deg float64, ref string) string {
+	deg = math.Mod(deg+360, 360)
+	if deg >= 359.95 {
+		deg = 0
+	}
+	return strconv.FormatFloat(deg, 'f', 1, 64) + "°" + ref
+}
+
+// Returns a position in decimal degrees in the internal position format
+func positionStr(lat, long float64) string {
+	latStr, longStr, _ := LatLongToString(lat, long)
+	return latStr + ", " + longStr
+}
+
+// Returns a time in the internal datetime format in UTC
+func dateTimeStr(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.00") + "+00:00"
+}
@@ -0,0 +1,172 @@
+package nmea0183
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type windState struct {
+	useSOG      bool    // boat speed from sog instead of stw
+	trueHeading bool    // heading from hdt instead of hdm and variation
+	damping     float64 // time constant in seconds, 0 for none
+	last        int64   // time of last computation in milliseconds
+	boat        [2]float64
+	earth       [2]float64
+	ground      [2]float64
+}
+
+// Knots in one unit of MWV wind speed
+var windUnits = map[string]float64{"N": 1, "K": 1 / 1.852, "M": 3600 / metresPerNm, "S": 1609.344 / metresPerNm}
+
+// Set true wind preferences used by TrueWind:
+// useSOG = true to use speed over ground instead of speed through water (stw) as boat speed
+// trueHeading = true to use hdt as heading, false for hdm corrected by variation
+// damping is a time constant in seconds used to smooth the wind, 0 for none
+func (h *Handle) WindPreferences(useSOG bool, trueHeading bool, damping float64) {
+	h.wind.useSOG = useSOG
+	h.wind.trueHeading = trueHeading
+	h.wind.damping = damping
+}
+
+// Computes true wind each time the apparent wind, boat speed or heading is updated, see TrueWind.
+// Only parsed values trigger it, not those derived by the handle eg hdt from AttachHeadings, so
+// damping is applied once per sentence.
+func (h *Handle) AttachTrueWind() {
+	h.onParsedUpdate(func(results map[string]string) {
+		for _, key := range []string{"wind_angle", "stw", "sog", "tmg", "hdm", "hdt", "mag_var", "hdg_var"} {
+			if _, ok := results[key]; ok {
+				h.TrueWind()
+				return
+			}
+		}
+	})
+}
+
+// Computes true wind from the apparent wind of an MWV with reference R, boat speed and heading
+// and stores the results in the variables used by MWD and VWT:
+//
+//	twa                   true wind angle from the bow, L negative
+//	tws, tws_ms, tws_kmh  true wind speed in knots, m/s and km/h
+//	twd, twd_mag          true wind direction, magnetic if variation is known
+//	gws, gwd              ground wind speed and direction if sog and tmg are known
+func (h *Handle) TrueWind() error {
+	if h.data["wind_ref"] != "R" {
+		return fmt.Errorf("no relative wind")
+	}
+	awa, errA := h.Float("wind_angle")
+	aws, errS := h.Float("wind_speed")
+	unit, ok := windUnits[h.data["wind_units"]]
+	if errA != nil || errS != nil || !ok {
+		return fmt.Errorf("relative wind is not valid")
+	}
+	aws *= unit
+
+	speedKey := "stw"
+	if h.wind.useSOG {
+		speedKey = "sog"
+	}
+	speed, err := h.Float(speedKey)
+	if err != nil {
+		return err
+	}
+	heading, err := h.trueHeading(h.wind.trueHeading)
+	if err != nil {
+		return err
+	}
+
+	// wind vectors are in the direction the wind blows from, x ahead and y to starboard
+	boat := [2]float64{aws*math.Cos(toRad(awa)) - speed, aws * math.Sin(toRad(awa))}
+	earthAngle := toRad(heading)
+	earth := [2]float64{
+		boat[0]*math.Cos(earthAngle) - boat[1]*math.Sin(earthAngle),
+		boat[0]*math.Sin(earthAngle) + boat[1]*math.Cos(earthAngle),
+	}
+
+	timeNow := h.timeNow()
+	alpha := 1.0
+	if h.wind.damping > 0 && h.wind.last > 0 {
+		alpha = 1 - math.Exp(-float64(timeNow-h.wind.last)/1000/h.wind.damping)
+	}
+	h.wind.last = timeNow
+	for i := range boat {
+		h.wind.boat[i] += alpha * (boat[i] - h.wind.boat[i])
+		h.wind.earth[i] += alpha * (earth[i] - h.wind.earth[i])
+	}
+	boat, earth = h.wind.boat, h.wind.earth
+
+	tws := math.Hypot(boat[0], boat[1])
+	twd := wrap360(toDeg(math.Atan2(earth[1], earth[0])))
+	values := map[string]string{
+		"twa":     strconv.FormatFloat(toDeg(math.Atan2(boat[1], boat[0])), 'f', 1, 64),
+		"tws":     strconv.FormatFloat(tws, 'f', 1, 64),
+		"tws_ms":  strconv.FormatFloat(tws*metresPerNm/3600, 'f', 1, 64),
+		"tws_kmh": strconv.FormatFloat(tws*1.852, 'f', 1, 64),
+		"twd":     compassStr(twd, "T"),
+	}
+	if magVar, _, err := h.variation(); err == nil {
+		values["twd_mag"] = compassStr(wrap360(twd-magVar), "M")
+	}
+
+	sog, errSog := h.Float("sog")
+	tmg, errTmg := h.Float("tmg")
+	if errSog == nil && errTmg == nil {
+		apparent := toRad(heading + awa)
+		ground := [2]float64{
+			aws*math.Cos(apparent) - sog*math.Cos(toRad(tmg)),
+			aws*math.Sin(apparent) - sog*math.Sin(toRad(tmg)),
+		}
+		for i := range ground {
+			h.wind.ground[i] += alpha * (ground[i] - h.wind.ground[i])
+		}
+		ground = h.wind.ground
+		values["gws"] = strconv.FormatFloat(math.Hypot(ground[0], ground[1]), 'f', 1, 64)
+		values["gwd"] = compassStr(wrap360(toDeg(math.Atan2(ground[1], ground[0]))), "T")
+	}
+	h.setVars(values)
+	return nil
+}
+
+// Returns the heading true from hdt if preferTrue or hdm and variation from HDG, RMC or the
+// magnetic model, falling back to the other if not known
+func (h *Handle) trueHeading(preferTrue bool) (float64, error) {
+	hdt, errT := h.Float("hdt")
+	hdm, errM := h.Float("hdm")
+	magVar, _, errV := h.variation()
+	magnetic := errM == nil && errV == nil
+	switch {
+	case errT == nil && (preferTrue || !magnetic):
+		return hdt, nil
+	case magnetic:
+		return wrap360(hdm + magVar), nil
+	}
+	return 0, fmt.Errorf("no true heading or magnetic heading and variation")
+}
+
+// Returns MWV with reference T, MWD and VWT sentences for the true wind, see TrueWind.
+// As with WriteNavigation the first error is returned.
+func (h *Handle) WriteTrueWind(manCode string) ([]string, error) {
+	var sentences []string
+	var firstErr error
+	twa, errA := h.Float("twa")
+	values := map[string]string{"wind_ref": "T", "wind_speed": h.data["tws"], "wind_units": "N", "wind_status": "A"}
+	if errA == nil {
+		values["wind_angle"] = strconv.FormatFloat(wrap360(twa), 'f', 1, 64)
+	}
+	s, err := h.WriteSentenceWith(manCode, "MWV", values)
+	if err == nil && errA != nil {
+		err = errA
+	}
+	if err != nil {
+		firstErr = fmt.Errorf("MWV: %w", err)
+	}
+	sentences = append(sentences, s)
+	for _, name := range []string{"MWD", "VWT"} {
+		s, err := h.WriteSentence(manCode, name)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+		sentences = append(sentences, s)
+	}
+	return sentences, firstErr
+}
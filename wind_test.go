@@ -0,0 +1,84 @@
+package nmea0183
+
+import (
+	"testing"
+)
+
+func TestTrueWind(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AttachTrueWind()
+	nm.Parse("$HCHDG,,,,10.0,W*24")
+	nm.Parse("$HCHDM,10.0,M*18")
+	nm.Parse("$VWVHM,,,,,6.0*56")
+	nm.Parse("$WIMWV,45.0,R,20.0,N,A*20")
+
+	expected := map[string]string{
+		"twa": "60.1", "tws": "16.3", "tws_ms": "8.4", "tws_kmh": "30.2",
+		"twd": "60.1°T", "twd_mag": "70.1°M",
+	}
+	for k, v := range expected {
+		if nm.Get(k) != v {
+			t.Errorf("%s expected %s got %s", k, v, nm.Get(k))
+		}
+	}
+	if _, ok := nm.GetMap()["gws"]; ok {
+		t.Error("ground wind needs sog and tmg")
+	}
+
+	nm.Update(map[string]string{"sog": "7.0", "tmg": "350.0"})
+	if nm.Get("gws") != "17.0" || nm.Get("gwd") != "64.7°T" {
+		t.Errorf("ground wind got %s %s", nm.Get("gws"), nm.Get("gwd"))
+	}
+
+	// port side in m/s
+	nm.Parse("$WIMWV,315.0,R,10.29,M,A*2D")
+	if nm.Get("twa") != "-60.1" || nm.Get("tws") != "16.3" {
+		t.Errorf("port wind got %s %s", nm.Get("twa"), nm.Get("tws"))
+	}
+
+	sentences, err := nm.WriteTrueWind("WI")
+	if err != nil {
+		t.Error(err)
+	}
+	expectedSentences := []string{
+		"$WIMWV,299.9,T,16.3,N,A*",
+		"$WIMWD,299.9,T,309.9,M,16.3,N,8.4,M*",
+		"$WIVWT,60.1,L,16.3,N,8.4,M,30.2,K*",
+	}
+	for i, s := range expectedSentences {
+		if len(sentences[i]) != len(s)+2 || sentences[i][:len(s)] != s {
+			t.Errorf("expected %s got %s", s, sentences[i])
+		}
+	}
+}
+
+func TestTrueWindFromParsedValues(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.AttachTrueWind()
+	nm.Parse("$HCHDG,,,,10.0,W*24")
+	nm.Parse("$HCHDM,10.0,M*18")
+	nm.Parse("$VWVHM,,,,,6.0*56")
+	nm.Parse("$WIMWV,45.0,R,20.0,N,A*20")
+	nm.setVars(map[string]string{"stw": "12.0"})
+	if nm.Get("twa") != "60.1" || nm.Get("tws") != "16.3" {
+		t.Errorf("a derived stw should not trigger true wind got %s %s", nm.Get("twa"), nm.Get("tws"))
+	}
+}
+
+func TestTrueWindSOGAndDamping(t *testing.T) {
+	nm := DefaultSentences().MakeHandle()
+	nm.Preferences(0, false)
+	nm.WindPreferences(true, true, 10)
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:09:10.00+00:00", "hdt": "0.0°T", "sog": "6.0", "tmg": "0.0"})
+	nm.Update(map[string]string{"wind_angle": "45.0", "wind_ref": "R", "wind_speed": "20.0", "wind_units": "N"})
+	if err := nm.TrueWind(); err != nil || nm.Get("tws") != "16.3" {
+		t.Errorf("true wind from sog got %s %v", nm.Get("tws"), err)
+	}
+
+	nm.Update(map[string]string{"datetime": "2020-09-15T11:09:20.00+00:00", "wind_speed": "10.0"})
+	nm.TrueWind()
+	tws, _ := nm.Float("tws")
+	if tws >= 16.3 || tws <= 6.0 {
+		t.Errorf("damped true wind speed should be between 6.0 and 16.3 got %f", tws)
+	}
+}